@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestReplaceMovieCredits(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	id := createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+	path := fmt.Sprintf("/v1/movies/%d/credits", id)
+
+	status, res := ts.do(t, http.MethodPost, "/v1/people", `{"name": "Ridley Scott", "birth_year": 1937}`, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("create person: status %d, body %v", status, res)
+	}
+	personID := int64(res["person"].(map[string]interface{})["id"].(float64))
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		key    string // Key of the expected validation error
+	}{
+		{"valid", fmt.Sprintf(`{"credits": [{"person_id": %d, "role": "director", "billing": 1}]}`, personID), http.StatusOK, ""},
+		{"null credit", `{"credits": [null]}`, http.StatusUnprocessableEntity, "credits.0"},
+		{"missing credits", `{}`, http.StatusUnprocessableEntity, "credits"},
+		{"unknown person", `{"credits": [{"person_id": 999, "role": "director", "billing": 1}]}`, http.StatusUnprocessableEntity, "credits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ts.do(t, http.MethodPut, path, tt.body, nil)
+			if status != tt.status {
+				t.Fatalf("status %d, body %v; want %d", status, body, tt.status)
+			}
+
+			if tt.key != "" && errorFor(body, tt.key) == "" {
+				t.Errorf("body %v, want a %s error", body, tt.key)
+			}
+		})
+	}
+}
+
+func TestMovieCreditsSearch(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	alien := createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+	blade := createTestMovie(t, ts, "Blade Runner", `["sci-fi"]`)
+	createTestMovie(t, ts, "Heat", `["crime"]`)
+
+	people := make(map[string]int64)
+	for _, name := range []string{"Ridley Scott", "Sigourney Weaver", "Harrison Ford"} {
+		status, res := ts.do(t, http.MethodPost, "/v1/people", fmt.Sprintf(`{"name": %q}`, name), nil)
+		if status != http.StatusCreated {
+			t.Fatalf("create person: status %d, body %v", status, res)
+		}
+		people[name] = int64(res["person"].(map[string]interface{})["id"].(float64))
+	}
+
+	credits := map[int64]string{
+		alien: fmt.Sprintf(`{"credits": [{"person_id": %d, "role": "director", "billing": 1}, {"person_id": %d, "role": "actor", "character": "Ripley", "billing": 2}]}`,
+			people["Ridley Scott"], people["Sigourney Weaver"]),
+		blade: fmt.Sprintf(`{"credits": [{"person_id": %d, "role": "director", "billing": 1}, {"person_id": %d, "role": "actor", "character": "Deckard", "billing": 2}]}`,
+			people["Ridley Scott"], people["Harrison Ford"]),
+	}
+	for id, body := range credits {
+		if status, res := ts.do(t, http.MethodPut, fmt.Sprintf("/v1/movies/%d/credits", id), body, nil); status != http.StatusOK {
+			t.Fatalf("replace credits: status %d, body %v", status, res)
+		}
+	}
+
+	// The credits are embedded in the movie on request, with the people's names.
+	status, body := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=credits", alien), "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("show movie: status %d, body %v", status, body)
+	}
+
+	var got []string
+	for _, credit := range body["movie"].(map[string]interface{})["credits"].([]interface{}) {
+		credit := credit.(map[string]interface{})
+		got = append(got, fmt.Sprintf("%s %s %v", credit["role"], credit["name"], credit["character"]))
+	}
+	if want := []string{"director Ridley Scott <nil>", "actor Sigourney Weaver Ripley"}; !slices.Equal(got, want) {
+		t.Errorf("credits = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"?director=ridley+scott", []int64{alien, blade}},
+		{"?director=Ridley+Scott&actor=Harrison+Ford", []int64{blade}},
+		{"?actor=Ridley+Scott", nil},
+		{"?writer=Ridley+Scott", nil},
+	}
+
+	for _, tt := range tests {
+		status, body := ts.do(t, http.MethodGet, "/v1/movies"+tt.query, "", nil)
+		if ids := movieIDs(body); status != http.StatusOK || !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: status %d, got movies %v; want %v", tt.query, status, ids, tt.want)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 )
 
+// The logError() method logs an error along with the details of the request, which
+// the logger takes from the request context.
 func (app *application) logError(r *http.Request, err error) {
-	app.logger.Println(err)
+	app.logger.ErrorContext(r.Context(), err.Error())
 }
 
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
@@ -22,6 +26,17 @@ func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Reques
 }
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	// Errors caused by the request context ending are not server faults, so give them
+	// their own responses.
+	switch {
+	case errors.Is(err, context.Canceled):
+		app.clientClosedRequestResponse(w, r, err)
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		app.timeoutResponse(w, r, err)
+		return
+	}
+
 	app.logError(r, err)
 	message := "the server encountered a problem and could not process yout request."
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
@@ -41,7 +56,36 @@ func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
 }
 
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, maxBytes int64) {
+	message := fmt.Sprintf("the request body must not be larger than %d bytes", maxBytes)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+}
+
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edi conflict, please try again"
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
+
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "rate limit exceeded"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been modified since you last retrieved it, please fetch it again"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+// The clientClosedRequestResponse() method is used when the client disconnected before
+// we finished processing its request. There is nobody left to read a response body, so
+// we just record the non-standard 499 status (as popularised by nginx) for the logs.
+func (app *application) clientClosedRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.InfoContext(r.Context(), "client closed request", "status", 499, "error", err.Error())
+	w.WriteHeader(499)
+}
+
+func (app *application) timeoutResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+	message := "the server timed out while processing your request, please try again"
+	app.errorResponse(w, r, http.StatusGatewayTimeout, message)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.abhishek/internal/data"
+	"greenlight.abhishek/internal/validator"
+)
+
+// exportFlushInterval is the number of movies written between each flush of the
+// response to the client.
+const exportFlushInterval = 100
+
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Format string
+		data.MovieSearch
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	var err error
+	input.MovieSearch, input.Filters, err = app.readMovieSearch(r.Context(), qs, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	input.Format = app.readString(qs, "format", exportFormat(r.Header.Get("Accept")))
+
+	// The export isn't paginated, so only the sort parameter needs checking.
+	v.Check(validator.In(input.Format, "ndjson", "csv"), "format", "must be ndjson or csv")
+	v.Check(validator.In(input.Filters.Sort, input.Filters.SortSafelist...), "sort", "invalid sort value")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// header writes anything which must precede the first movie, write writes a single
+	// movie and flush pushes any data buffered by the encoder into the response.
+	var header, flush func() error
+	var write func(*data.Movie) error
+
+	switch input.Format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		header = func() error {
+			return cw.Write(movieCSVRecord(nil))
+		}
+		write = func(movie *data.Movie) error {
+			return cw.Write(movieCSVRecord(movie))
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="movies.csv"`)
+	default:
+		enc := json.NewEncoder(w)
+		header = func() error { return nil }
+		write = func(movie *data.Movie) error {
+			return enc.Encode(movie)
+		}
+		flush = func() error { return nil }
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="movies.ndjson"`)
+	}
+
+	// The export can take far longer than the server's WriteTimeout, so extend the write
+	// deadline for this response to match the export query timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(app.config.db.timeouts.export)); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Nothing (not even the status code) is sent until the first movie arrives, so that
+	// an error from the query itself can still be reported as a normal JSON error
+	// response.
+	written := 0
+
+	err = app.models.Movies.Export(r.Context(), input.MovieSearch, input.Filters, func(movie *data.Movie) error {
+		if written == 0 {
+			if err := header(); err != nil {
+				return err
+			}
+		}
+
+		if err := write(movie); err != nil {
+			return err
+		}
+
+		written++
+
+		// Push what we have so far to the client every so often, rather than letting it
+		// all pile up in the response buffer.
+		if written%exportFlushInterval == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if written == 0 {
+			w.Header().Del("Content-Disposition")
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// Part of the export has already been sent with a 200 OK status, so the best we
+		// can do is log the error and abort the connection. The client then sees a
+		// truncated response rather than a seemingly complete one.
+		app.logError(r, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	if written == 0 {
+		if err := header(); err != nil {
+			app.logError(r, err)
+			return
+		}
+	}
+
+	if err := flush(); err != nil {
+		app.logError(r, err)
+	}
+}
+
+// movieCSVRecord converts a movie to a CSV record. A nil movie produces the header row.
+func movieCSVRecord(movie *data.Movie) []string {
+	if movie == nil {
+		return []string{"id", "created_at", "title", "year", "runtime", "genres", "rating", "rating_count", "poster_url", "version"}
+	}
+
+	return []string{
+		strconv.FormatInt(movie.ID, 10),
+		movie.CreatedAt.Format(time.RFC3339),
+		movie.Title,
+		strconv.FormatInt(int64(movie.Year), 10),
+		strconv.FormatInt(int64(movie.Runtime), 10),
+		strings.Join(movie.Genres, ";"),
+		strconv.FormatFloat(movie.Rating, 'f', -1, 64),
+		strconv.FormatInt(int64(movie.RatingCount), 10),
+		movie.Poster[data.PosterOriginal].URL,
+		strconv.FormatInt(int64(movie.Version), 10),
+	}
+}
+
+// exportFormat picks the export format from the Accept header, for when the format
+// query string parameter is not given. NDJSON is the default.
+func exportFormat(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case "text/csv":
+			return "csv"
+		case "application/x-ndjson", "application/ndjson":
+			return "ndjson"
+		}
+	}
+
+	return "ndjson"
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"greenlight.abhishek/internal/data"
+	"greenlight.abhishek/internal/validator"
+)
+
+// movieExtraFields are the keys of the Movie JSON which aren't governed by the fields
+// parameter, since they are only present when the client asks for them some other
+// way, with a title search, include=credits or by looking in the trash.
+var movieExtraFields = []string{"deleted_at", "match", "credits"}
+
+// sparseMovie is a movie which is written to JSON with only some of its fields.
+type sparseMovie struct {
+	movie  *data.Movie
+	fields []string
+}
+
+// MarshalJSON writes the requested fields of the movie, in the same order as the full
+// Movie JSON.
+func (s sparseMovie) MarshalJSON() ([]byte, error) {
+	js, err := json.Marshal(s.movie)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(js, &values); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for _, field := range append(append([]string{}, data.MovieFields...), movieExtraFields...) {
+		value, ok := values[field]
+		if !ok || !(validator.In(field, s.fields...) || validator.In(field, movieExtraFields...)) {
+			continue
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(field)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// The movieWithFields() helper returns the value to write to JSON for a movie: the
+// movie itself, or a sparseMovie when the client asked for particular fields.
+func movieWithFields(movie *data.Movie, fields []string) interface{} {
+	if len(fields) == 0 {
+		return movie
+	}
+
+	return sparseMovie{movie: movie, fields: fields}
+}
+
+// The moviesWithFields() helper does the same as movieWithFields() for a list of
+// movies.
+func moviesWithFields(movies []*data.Movie, fields []string) interface{} {
+	if len(fields) == 0 {
+		return movies
+	}
+
+	sparse := make([]sparseMovie, len(movies))
+	for i, movie := range movies {
+		sparse[i] = sparseMovie{movie: movie, fields: fields}
+	}
+
+	return sparse
+}
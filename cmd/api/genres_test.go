@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestCreateGenre(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		key    string // Key of the expected validation error
+	}{
+		{"valid", `{"slug": "noir", "name": "Film Noir", "synonyms": ["noir films"]}`, http.StatusCreated, ""},
+		{"slug used as a synonym", `{"slug": "sf", "name": "Speculative", "synonyms": []}`, http.StatusUnprocessableEntity, "slug"},
+		{"name used by another genre", `{"slug": "drama-2", "name": "Drama", "synonyms": []}`, http.StatusUnprocessableEntity, "name"},
+		{"duplicate slug", `{"slug": "noir", "name": "Noir", "synonyms": []}`, http.StatusUnprocessableEntity, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ts.do(t, http.MethodPost, "/v1/genres", tt.body, nil)
+			if status != tt.status {
+				t.Fatalf("status %d, body %v; want %d", status, body, tt.status)
+			}
+
+			if tt.key != "" && errorFor(body, tt.key) == "" {
+				t.Errorf("body %v, want a %s error", body, tt.key)
+			}
+		})
+	}
+}
+
+func TestGenreCatalogueInUse(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	status, body := ts.do(t, http.MethodPost, "/v1/genres", `{"slug": "noir", "name": "Film Noir", "synonyms": ["noir films"]}`, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("create genre: status %d, body %v", status, body)
+	}
+
+	// A new genre can be used straight away, by any of its names, and is stored as its
+	// slug.
+	id := createTestMovie(t, ts, "The Big Sleep", `["Noir Films", "Crime"]`)
+
+	status, body = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", id), "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("show movie: status %d, body %v", status, body)
+	}
+
+	var genres []string
+	for _, genre := range body["movie"].(map[string]interface{})["genres"].([]interface{}) {
+		genres = append(genres, genre.(string))
+	}
+	if !slices.Equal(genres, []string{"noir", "crime"}) {
+		t.Errorf("genres = %v, want [noir crime]", genres)
+	}
+
+	// The genre can't be deleted while a movie still has it.
+	status, _ = ts.do(t, http.MethodDelete, "/v1/genres/noir", "", nil)
+	if status != http.StatusConflict {
+		t.Errorf("delete genre in use: status %d, want 409", status)
+	}
+}
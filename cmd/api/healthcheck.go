@@ -4,16 +4,24 @@ import (
 	"net/http"
 )
 
+// healthcheckHandler reports whether the server is available. Once it has started
+// shutting down, it responds with "draining" and a 503 Service Unavailable status, so
+// that load balancers stop sending it new requests.
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	status, code := "available", http.StatusOK
+	if app.draining.Load() {
+		status, code = "draining", http.StatusServiceUnavailable
+	}
+
 	env := envelope{
-		"status": "available",
+		"status": status,
 		"system_info": map[string]string{
 			"environment": app.config.env,
 			"version":     version,
 		},
 	}
 
-	err := app.writeJSON(w, http.StatusOK, env, nil)
+	err := app.writeJSON(w, code, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
+	"greenlight.abhishek/internal/data"
 	"greenlight.abhishek/internal/validator"
 )
 
@@ -27,9 +28,81 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// readSlugParam() returns the slug URL parameter. Any string is accepted, since an
+// invalid slug simply won't match a record.
+func (app *application) readSlugParam(r *http.Request) string {
+	return httprouter.ParamsFromContext(r.Context()).ByName("slug")
+}
+
+// The movieETag() helper returns the entity tag for the current version of a movie.
+// Every edit to a movie increments its version, but reviews change the rating without
+// touching the version, so the rating is part of the tag too.
+func movieETag(movie *data.Movie) string {
+	return fmt.Sprintf(`"%d-%d-%d-%g"`, movie.ID, movie.Version, movie.RatingCount, movie.Rating)
+}
+
+// The etagMatches() helper reports whether the value of an If-Match or If-None-Match
+// header matches the given entity tag. The header may hold a list of tags or "*", which
+// matches any tag. If-Match uses the strong comparison, under which weak tags (prefixed
+// with W/) never match, while If-None-Match uses the weak comparison.
+func etagMatches(header, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(candidate, "W/") {
+			if strong {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The readVersionParam() helper reads the "version" URL parameter, which identifies a
+// revision of a movie.
+func (app *application) readVersionParam(r *http.Request) (int32, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	version, err := strconv.ParseInt(params.ByName("version"), 10, 32)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid version parameter")
+	}
+
+	return int32(version), nil
+}
+
+// The readReviewIDParam() helper reads the "review_id" URL parameter, which identifies
+// a review of a movie.
+func (app *application) readReviewIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName("review_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid review_id parameter")
+	}
+
+	return id, nil
+}
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
-	// Use http.MaxBytesReader() to limit the size of the request body to 1MB.
-	var maxBytes int64 = 1 << 20
+	// Limit the size of the request body to 1MB.
+	return app.readJSONWithLimit(w, r, dst, 1<<20)
+}
+
+// The readJSONWithLimit() helper works like readJSON() but with a custom limit on the
+// size of the request body, for endpoints which accept larger payloads.
+func (app *application) readJSONWithLimit(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int64) error {
+	// Use http.MaxBytesReader() to limit the size of the request body.
 	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 
 	// Decoding request body
@@ -150,3 +223,46 @@ func (app *application) readInts(qs url.Values, key string, defaultValue int, v
 	// Otherwise, return the converted interger value.
 	return i
 }
+
+// The paginationLinks() helper builds the value of an RFC 8288 Link header pointing at
+// the first, previous, next and last pages of a paginated listing. The links reuse the
+// request's own query string with only the page parameter replaced, so any filters and
+// sorting are preserved. In keyset mode only a "next" link carrying the cursor can be
+// produced. An empty string is returned if there is nothing to link to.
+func (app *application) paginationLinks(r *http.Request, metadata data.Metadata) string {
+	link := func(key, value, rel string) string {
+		qs := r.URL.Query()
+		qs.Set(key, value)
+		u := url.URL{Path: r.URL.Path, RawQuery: qs.Encode()}
+		return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+	}
+
+	if r.URL.Query().Get("cursor") != "" {
+		if metadata.NextCursor == "" {
+			return ""
+		}
+		return link("cursor", metadata.NextCursor, "next")
+	}
+
+	if metadata.TotalRecords == 0 {
+		return ""
+	}
+
+	page := func(page int, rel string) string {
+		return link("page", strconv.Itoa(page), rel)
+	}
+
+	links := []string{page(metadata.FirstPage, "first")}
+
+	if metadata.CurrentPage > metadata.FirstPage {
+		links = append(links, page(metadata.CurrentPage-1, "prev"))
+	}
+
+	if metadata.CurrentPage < metadata.LastPage {
+		links = append(links, page(metadata.CurrentPage+1, "next"))
+	}
+
+	links = append(links, page(metadata.LastPage, "last"))
+
+	return strings.Join(links, ", ")
+}
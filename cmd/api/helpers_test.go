@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `"1-2"`
+
+	tests := []struct {
+		name   string
+		header string
+		strong bool
+		want   bool
+	}{
+		{"exact", `"1-2"`, true, true},
+		{"different", `"1-1"`, true, false},
+		{"wildcard", `*`, true, true},
+		{"list", `"1-1", "1-2"`, true, true},
+		{"list without spaces", `"1-1","1-2"`, true, true},
+		{"weak with strong comparison", `W/"1-2"`, true, false},
+		{"weak with weak comparison", `W/"1-2"`, false, true},
+		{"unquoted", `1-2`, false, false},
+		{"empty", ``, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.header, etag, tt.strong); got != tt.want {
+				t.Errorf("etagMatches(%q, %q, %v) = %v, want %v", tt.header, etag, tt.strong, got, tt.want)
+			}
+		})
+	}
+}
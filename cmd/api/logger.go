@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"greenlight.abhishek/internal/data"
+)
+
+const requestInfoContextKey = contextKey("request_info")
+
+type contextKey string
+
+// requestInfo describes the request being served, for logging.
+type requestInfo struct {
+	method string
+	url    string
+}
+
+// contextWithRequestInfo returns a copy of the context carrying the method and URL of
+// the request, for the logger to add to records.
+func contextWithRequestInfo(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, requestInfoContextKey, requestInfo{method: r.Method, url: r.URL.RequestURI()})
+}
+
+// newLogger returns a logger which writes JSON in production, where logs are collected
+// by machines, and more readable text elsewhere. Records logged with a context add the
+// details of the request it belongs to.
+func newLogger(w io.Writer, env string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if env == "development" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// contextHandler is a slog.Handler which adds the method, URL and ID of the request
+// being served, taken from the context, to every record logged with one. This works
+// the same for records from the handlers and from the data layer, since the data
+// layer uses the default logger and is passed the request context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info, ok := ctx.Value(requestInfoContextKey).(requestInfo); ok {
+		record.AddAttrs(
+			slog.String("request_method", info.method),
+			slog.String("request_url", info.url),
+		)
+	}
+
+	if id := data.RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
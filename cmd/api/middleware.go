@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.abhishek/internal/data"
+)
+
+// middleware wraps a handler with some behaviour of its own.
+type middleware func(http.Handler) http.Handler
+
+// chain wraps handler with each of the middleware in turn, so that the first one listed
+// is the outermost and sees every request first.
+func chain(handler http.Handler, mw ...middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
+
+// routeGroup registers routes on a router with a shared list of middleware, which is
+// applied to those routes only.
+type routeGroup struct {
+	router     *httprouter.Router
+	middleware []middleware
+}
+
+// group returns a new group which applies the given middleware after the group's own.
+func (g *routeGroup) group(mw ...middleware) *routeGroup {
+	return &routeGroup{
+		router:     g.router,
+		middleware: append(append([]middleware{}, g.middleware...), mw...),
+	}
+}
+
+// handle registers a handler for the method and path, wrapped with the group's
+// middleware.
+func (g *routeGroup) handle(method, path string, handler http.HandlerFunc) {
+	g.router.Handler(method, path, chain(handler, g.middleware...))
+}
+
+// The recoverPanic() middleware turns a panic in a handler into a 500 Internal Server
+// Error response, instead of the connection being dropped without an explanation.
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The deferred function always runs as the stack unwinds following a panic.
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			// http.ErrAbortHandler is how a handler deliberately aborts a response,
+			// which the server handles quietly.
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			// Make the server close the connection after this response, since it's
+			// unclear what state it is in.
+			w.Header().Set("Connection", "close")
+			app.serverErrorResponse(w, r, fmt.Errorf("panic: %v", err))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The cacheControl() middleware sets the Cache-Control header of responses, unless the
+// handler sets a different one.
+func (app *application) cacheControl(value string) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// The enableCORS() middleware allows browser applications on the trusted origins to
+// call the API. A request from one of them gets its origin echoed back in the
+// Access-Control-Allow-Origin header, and a preflight request for a method such as
+// PATCH or DELETE is answered here with the methods and headers it may use.
+func (app *application) enableCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the Origin header, so caches must keep a copy for
+		// each origin. Preflight responses also depend on the requested method.
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+
+		origin := r.Header.Get("Origin")
+
+		if origin != "" && slices.Contains(app.config.cors.trustedOrigins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+
+			// A preflight request is an OPTIONS request which says which method the
+			// actual request will use.
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, POST, PUT, PATCH, DELETE")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match, X-Request-ID")
+				w.Header().Set("Access-Control-Max-Age", "3600")
+
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			// Let scripts read the headers they need to make conditional requests,
+			// follow created resources and back off when rate limited.
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Location, X-Request-ID, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requestID() middleware makes sure that every request has an ID, which is stored
+// in the request context and echoed back in the X-Request-ID response header. An ID
+// supplied by the client (or a proxy in front of us) is reused if it looks sane,
+// otherwise a random one is generated.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+
+		if !validRequestID(id) {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		// Along with the ID, record the method and URL of the request in its context, so
+		// that they are added to everything logged while serving it.
+		ctx := data.ContextWithRequestID(r.Context(), id)
+		r = r.WithContext(contextWithRequestInfo(ctx, r))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The logRequest() middleware logs each request once it has been served, with the
+// status of the response and how long it took.
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		app.logger.InfoContext(r.Context(), "request completed",
+			"status", sw.status(),
+			"duration", time.Since(start).String(),
+		)
+	})
+}
+
+// statusWriter records the status code written to the http.ResponseWriter it wraps.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.code == 0 {
+		sw.code = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, for flushing and
+// deadlines.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// status returns the status code of the response, which is 200 OK if the handler
+// didn't write anything at all.
+func (sw *statusWriter) status() int {
+	if sw.code == 0 {
+		return http.StatusOK
+	}
+	return sw.code
+}
+
+// validRequestID reports whether a client supplied request ID is short and only
+// contains printable ASCII characters, so that it is safe to store and log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"greenlight.abhishek/internal/data"
 	"greenlight.abhishek/internal/validator"
@@ -31,17 +34,24 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Genres:  input.Genres,
 	}
 
+	// Fetch the genre catalogue, which the movie's genres are validated against.
+	genres, err := app.models.Genres.Catalogue(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Initialize a new Validator
 	v := validator.New()
 
 	// Call the ValidationMovie() function and returns a response containinng the errors if
 	// any of the checks fail.
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err := app.models.Movies.Insert(movie)
+	err = app.models.Movies.Insert(r.Context(), movie)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -49,6 +59,7 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("ETag", movieETag(movie))
 
 	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
 	if err != nil {
@@ -56,6 +67,91 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// maxBulkMovies is the maximum number of movies accepted by a single bulk request.
+const maxBulkMovies = 5000
+
+func (app *application) bulkCreateMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input []struct {
+		Title   string       `json:"title"`
+		Year    int32        `json:"year"`
+		Runtime data.Runtime `json:"runtime"`
+		Genres  []string     `json:"genres"`
+	}
+
+	v := validator.New()
+
+	// In atomic mode (the default) nothing is inserted unless every movie is valid. In
+	// best_effort mode the valid movies are inserted and the invalid ones reported.
+	mode := app.readString(r.URL.Query(), "mode", "atomic")
+	if v.Check(validator.In(mode, "atomic", "best_effort"), "mode", "must be atomic or best_effort"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Allow a larger request body than usual, since it holds many movies.
+	if err := app.readJSONWithLimit(w, r, &input, 10<<20); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v.Check(len(input) >= 1, "movies", "must contain at least 1 movie")
+	v.Check(len(input) <= maxBulkMovies, "movies", fmt.Sprintf("must not contain more than %d movies", maxBulkMovies))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	genres, err := app.models.Genres.Catalogue(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Validate each movie separately, collecting the errors keyed by the index of the
+	// movie in the request body.
+	movies := make([]*data.Movie, 0, len(input))
+	itemErrors := make(map[string]map[string]string)
+
+	for i, item := range input {
+		movie := &data.Movie{
+			Title:   item.Title,
+			Year:    item.Year,
+			Runtime: item.Runtime,
+			Genres:  item.Genres,
+		}
+
+		iv := validator.New()
+		if data.ValidateMovie(iv, movie, genres); !iv.Valid() {
+			itemErrors[strconv.Itoa(i)] = iv.Errors
+			continue
+		}
+
+		movies = append(movies, movie)
+	}
+
+	if len(movies) == 0 || (mode == "atomic" && len(itemErrors) > 0) {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, itemErrors)
+		return
+	}
+
+	err = app.models.Movies.InsertMany(r.Context(), movies)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"movies": movies}
+	if len(itemErrors) > 0 {
+		env["errors"] = itemErrors
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -63,7 +159,28 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	// The include parameter lists related resources to embed in the movie, and the
+	// fields parameter limits the movie to the listed fields.
+	include := app.readCSV(r.URL.Query(), "include", []string{})
+	fields := app.readCSV(r.URL.Query(), "fields", []string{})
+
+	v := validator.New()
+	for _, name := range include {
+		v.Check(validator.In(name, "credits"), "include", "invalid include value")
+	}
+
+	if data.ValidateFields(v, fields); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The ETag is worked out from the version and rating, so those are always read.
+	columns := fields
+	if len(fields) > 0 {
+		columns = append(append([]string{}, fields...), "version", "rating", "rating_count")
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id, columns...)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -75,8 +192,30 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	headers := make(http.Header)
+
+	if validator.In("credits", include...) {
+		movie.Credits, err = app.models.Credits.GetForMovie(r.Context(), id)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	} else {
+		// If the client already has the current version of the movie, tell it so instead
+		// of sending the movie again. Credits can change without the movie's version
+		// changing, so this only applies when they aren't embedded.
+		etag := movieETag(movie)
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag, false) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		headers.Set("ETag", etag)
+	}
+
 	// Encode the struct to json and send it as the HTTP response.
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movieWithFields(movie, fields)}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -92,7 +231,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// Fetch the existing movie record from the database, sending a 404 Not found
 	// response to the client if we couldn't find a matching record.
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -103,6 +242,14 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// If the client sent an If-Match header, only go ahead if it was based on the
+	// version we just fetched. Otherwise it would overwrite changes it has never seen.
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !etagMatches(ifMatch, movieETag(movie), true) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
 	// Declare an input struct to hold the expected data from the client.
 	var input struct {
 		Title   *string       `json:"title"`
@@ -135,19 +282,29 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		movie.Genres = input.Genres
 	}
 
+	genres, err := app.models.Genres.Catalogue(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// validate the updated movie record, sending the client a 422 unprocessable Entity
 	// response if any checks fail.
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
 	// Pass the updated movie record to our new Update() method.
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(r.Context(), movie)
 	if err != nil {
 		switch {
+		// With If-Match, a change made since we fetched the movie means the client's
+		// precondition no longer holds.
+		case errors.Is(err, data.ErrEditConflict) && ifMatch != "":
+			app.preconditionFailedResponse(w, r)
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
 		default:
@@ -156,8 +313,11 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
 	// Write the updated movie record in a json response
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -170,13 +330,41 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Delete the movie from the database, sending a 404 Not found response to the
-	// client if there isn't a matching record.
-	err = app.models.Movies.Delete(id)
+	// If the client sent an If-Match header, check that it still has the current version
+	// of the movie before deleting it. The delete itself is then made conditional on
+	// that version, so that a change made in between isn't lost either.
+	var version int32
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" {
+		movie, err := app.models.Movies.Get(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if !etagMatches(ifMatch, movieETag(movie), true) {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+
+		version = movie.Version
+	}
+
+	// Move the movie to the trash, sending a 404 Not found response to the client if
+	// there isn't a matching record.
+	err = app.models.Movies.Delete(r.Context(), id, version)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -190,36 +378,211 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Take the movie out of the trash, sending a 404 Not Found response if there is no
+	// trashed movie with this ID.
+	movie, err := app.models.Movies.Restore(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listTrashedMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
 		data.Filters
 	}
 
-	// Initialize a new validator instance.
 	v := validator.New()
 	qs := r.URL.Query()
 
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
 	input.Filters.Page = app.readInts(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInts(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.CursorSecret = app.config.cursor.secret
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAllTrashed(r.Context(), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	if links := app.paginationLinks(r, metadata); links != "" {
+		headers.Set("Link", links)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) purgeMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Only movies which are already in the trash can be purged, so a live movie gets a
+	// 404 Not Found response here just like a missing one.
+	movie, err := app.models.Movies.Purge(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies}, nil)
+	// The movie's poster images are no use to anyone now.
+	app.background(context.WithoutCancel(r.Context()), func(ctx context.Context) {
+		app.deleteBlobs(ctx, movie.Poster.Keys())
+	})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie permanently deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readMovieSearch reads the search parameters shared by the movie list and export
+// endpoints from the query string, along with the sort order, and validates the
+// search. Validation errors are recorded in v, while the returned error is for a
+// failure to fetch the genre catalogue.
+func (app *application) readMovieSearch(ctx context.Context, qs url.Values, v *validator.Validator) (data.MovieSearch, data.Filters, error) {
+	var search data.MovieSearch
+	var filters data.Filters
+
+	search.Title = app.readString(qs, "title", "")
+	search.Language = app.readString(qs, "language", "simple")
+	search.Mode = app.readString(qs, "mode", "exact")
+	search.Genres = app.readCSV(qs, "genres", []string{})
+	search.GenresMode = app.readString(qs, "genres_mode", "all")
+	search.YearMin = app.readInts(qs, "year_min", 0, v)
+	search.YearMax = app.readInts(qs, "year_max", 0, v)
+	search.RuntimeMin = app.readInts(qs, "runtime_min", 0, v)
+	search.RuntimeMax = app.readInts(qs, "runtime_max", 0, v)
+	search.Director = app.readString(qs, "director", "")
+	search.Writer = app.readString(qs, "writer", "")
+	search.Actor = app.readString(qs, "actor", "")
+
+	// Fuzzy matches are only useful with the most similar titles first, so sort them by
+	// relevance unless the client asks otherwise.
+	defaultSort := "id"
+	if search.Mode == "fuzzy" {
+		defaultSort = "relevance"
+	}
+
+	filters.Sort = app.readString(qs, "sort", defaultSort)
+	filters.SortSafelist = []string{"id", "title", "year", "runtime", "rating", "relevance", "-id", "-title", "-year", "-runtime", "-rating"}
+
+	data.ValidateMovieSearch(v, search)
+
+	// Genres are filtered on by slug, so the names sent by the client have to be
+	// resolved through the catalogue first.
+	if len(search.Genres) > 0 {
+		genres, err := app.models.Genres.Catalogue(ctx)
+		if err != nil {
+			return search, filters, err
+		}
+
+		data.ResolveSearchGenres(v, &search, genres)
+	}
+
+	return search, filters, nil
+}
+
+func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.MovieSearch
+		data.Filters
+		Facets []string
+	}
+
+	// Initialize a new validator instance.
+	v := validator.New()
+	qs := r.URL.Query()
+
+	var err error
+	input.MovieSearch, input.Filters, err = app.readMovieSearch(r.Context(), qs, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	input.Filters.Page = app.readInts(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInts(qs, "page_size", 20, v)
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.CursorSecret = app.config.cursor.secret
+	input.Filters.Fields = app.readCSV(qs, "fields", []string{})
+	input.Facets = app.readCSV(qs, "facets", []string{})
+
+	data.ValidateFields(v, input.Filters.Fields)
+	data.ValidateFacets(v, input.Facets)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(r.Context(), input.MovieSearch, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"movies": moviesWithFields(movies, input.Filters.Fields), "metadata": metadata}
+
+	// Facet counts are only worked out when asked for, since they have to look at every
+	// matching movie rather than a single page.
+	if len(input.Facets) > 0 {
+		facets, err := app.models.Movies.Facets(r.Context(), input.MovieSearch, input.Facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		env["facets"] = facets
+	}
+
+	// Advertise the neighbouring pages in a Link header so that clients can page through
+	// the results without building the URLs themselves.
+	headers := make(http.Header)
+	if links := app.paginationLinks(r, metadata); links != "" {
+		headers.Set("Link", links)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
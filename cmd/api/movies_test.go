@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// createTestMovie creates a movie through the API and returns its id.
+func createTestMovie(t *testing.T, ts *testServer, title string, genres string) int64 {
+	t.Helper()
+
+	return createTestMovieYear(t, ts, title, 1979, genres)
+}
+
+// createTestMovieYear is like createTestMovie, but with the given release year.
+func createTestMovieYear(t *testing.T, ts *testServer, title string, year int, genres string) int64 {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"title": %q, "year": %d, "runtime": "117 mins", "genres": %s}`, title, year, genres)
+
+	status, res := ts.do(t, http.MethodPost, "/v1/movies", body, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("create movie: status %d, body %v", status, res)
+	}
+
+	movie := res["movie"].(map[string]interface{})
+	return int64(movie["id"].(float64))
+}
+
+func TestListMoviesCursor(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	for i, year := range []int{1999, 1985, 1999, 2001, 1985, 1999, 1979} {
+		createTestMovieYear(t, ts, fmt.Sprintf("Movie %d", i+1), year, `["drama"]`)
+	}
+
+	// The first page uses offset pagination, and each page after it follows the cursor
+	// of the one before.
+	status, body := ts.do(t, http.MethodGet, "/v1/movies?sort=-year&page_size=3", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("first page: status %d, body %v", status, body)
+	}
+
+	pages := [][]int64{movieIDs(body)}
+
+	// A movie added after the first page sorts before the cursor, so it mustn't shift
+	// the later pages the way it would with offsets.
+	createTestMovieYear(t, ts, "Movie 8", 2005, `["drama"]`)
+
+	for {
+		cursor, _ := body["metadata"].(map[string]interface{})["next_cursor"].(string)
+		if cursor == "" {
+			break
+		}
+
+		status, body = ts.do(t, http.MethodGet, "/v1/movies?sort=-year&page_size=3&cursor="+url.QueryEscape(cursor), "", nil)
+		if status != http.StatusOK {
+			t.Fatalf("page %d: status %d, body %v", len(pages)+1, status, body)
+		}
+
+		pages = append(pages, movieIDs(body))
+	}
+
+	want := [][]int64{{4, 1, 3}, {6, 2, 5}, {7}}
+	if !slices.EqualFunc(pages, want, slices.Equal[[]int64]) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	// A cursor can only continue the ordering it was issued for.
+	status, body = ts.do(t, http.MethodGet, "/v1/movies?sort=title&page_size=3&cursor=nope", "", nil)
+	if status != http.StatusUnprocessableEntity || errorFor(body, "cursor") == "" {
+		t.Errorf("invalid cursor: status %d, body %v; want 422 with a cursor error", status, body)
+	}
+}
+
+func TestListMoviesTitleSearch(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	createTestMovie(t, ts, "Star Trek: The Motion Picture", `["sci-fi"]`)
+	createTestMovie(t, ts, "Star Wars", `["sci-fi"]`)
+	createTestMovie(t, ts, "Lone Star", `["drama"]`)
+	createTestMovie(t, ts, "Stardust", `["fantasy"]`)
+	createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+
+	// Every word of the search has to match, and the movies whose titles are mostly made
+	// up of matching words come first.
+	status, body := ts.do(t, http.MethodGet, "/v1/movies?title=star&sort=relevance", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("status %d, body %v", status, body)
+	}
+
+	if ids := movieIDs(body); !slices.Equal(ids, []int64{2, 3, 1}) {
+		t.Errorf("star: got movies %v, want [2 3 1]", ids)
+	}
+
+	match := body["movies"].([]interface{})[0].(map[string]interface{})["match"].(map[string]interface{})
+	if match["headline"] != "<b>Star</b> Wars" || match["relevance"] != 0.5 {
+		t.Errorf("star: match = %v, want a highlighted headline and a relevance of 0.5", match)
+	}
+
+	// A trailing star turns the last word into a prefix.
+	status, body = ts.do(t, http.MethodGet, "/v1/movies?title=star*", "", nil)
+	if ids := movieIDs(body); status != http.StatusOK || !slices.Equal(ids, []int64{1, 2, 3, 4}) {
+		t.Errorf("star*: status %d, got movies %v; want [1 2 3 4]", status, ids)
+	}
+
+	status, body = ts.do(t, http.MethodGet, "/v1/movies?title=star+wars", "", nil)
+	if ids := movieIDs(body); status != http.StatusOK || !slices.Equal(ids, []int64{2}) {
+		t.Errorf("star wars: status %d, got movies %v; want [2]", status, ids)
+	}
+}
+
+func TestListMoviesFuzzy(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+	createTestMovie(t, ts, "Aliens", `["sci-fi"]`)
+	createTestMovie(t, ts, "Heat", `["crime"]`)
+
+	// Fuzzy matches are sorted by similarity unless another sort is asked for.
+	status, body := ts.do(t, http.MethodGet, "/v1/movies?mode=fuzzy&title=aliens", "", nil)
+	if ids := movieIDs(body); status != http.StatusOK || !slices.Equal(ids, []int64{2, 1}) {
+		t.Errorf("fuzzy: status %d, got movies %v; want [2 1]", status, ids)
+	}
+
+	status, body = ts.do(t, http.MethodGet, "/v1/movies?mode=fuzzy", "", nil)
+	if status != http.StatusUnprocessableEntity || errorFor(body, "title") == "" {
+		t.Errorf("fuzzy without a title: status %d, body %v; want 422 with a title error", status, body)
+	}
+
+	// An exact search which finds nothing suggests the closest title instead.
+	status, body = ts.do(t, http.MethodGet, "/v1/movies?title=alienz", "", nil)
+	if status != http.StatusOK || len(movieIDs(body)) != 0 {
+		t.Fatalf("misspelt: status %d, body %v; want no movies", status, body)
+	}
+
+	if suggestion := body["metadata"].(map[string]interface{})["suggestion"]; suggestion != "Alien" {
+		t.Errorf("misspelt: suggestion = %v, want Alien", suggestion)
+	}
+}
+
+func TestListMoviesFilters(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	createTestMovieYear(t, ts, "Alien", 1979, `["sci-fi", "horror"]`)
+	createTestMovieYear(t, ts, "Heat", 1995, `["crime", "drama"]`)
+	createTestMovieYear(t, ts, "Gattaca", 1997, `["sci-fi", "drama"]`)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int64
+	}{
+		{"one genre", "?genres=sci-fi", []int64{1, 3}},
+		{"all genres", "?genres=sci-fi,drama", []int64{3}},
+		{"any genre", "?genres=horror,crime&genres_mode=any", []int64{1, 2}},
+		{"year range", "?year_min=1990&year_max=1996", []int64{2}},
+		{"open year range", "?year_min=1996", []int64{3}},
+		{"combined", "?genres=drama&year_max=1996", []int64{2}},
+		{"genre name", "?genres=Science+Fiction", []int64{1, 3}},
+		{"genre synonym", "?genres=SF,Drama", []int64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ts.do(t, http.MethodGet, "/v1/movies"+tt.query, "", nil)
+			if ids := movieIDs(body); status != http.StatusOK || !slices.Equal(ids, tt.want) {
+				t.Errorf("list: status %d, got movies %v; want %v", status, ids, tt.want)
+			}
+
+			// The export takes the same search parameters, and writes one movie per line.
+			status, raw := ts.doRaw(t, http.MethodGet, "/v1/movies/export"+tt.query, "", nil)
+			if lines := bytes.Count(raw, []byte("\n")); status != http.StatusOK || lines != len(tt.want) {
+				t.Errorf("export: status %d, got %d movies; want %d", status, lines, len(tt.want))
+			}
+		})
+	}
+
+	status, body := ts.do(t, http.MethodGet, "/v1/movies?year_min=2000&year_max=1990", "", nil)
+	if status != http.StatusUnprocessableEntity || errorFor(body, "year_max") == "" {
+		t.Errorf("reversed range: status %d, body %v; want 422 with a year_max error", status, body)
+	}
+
+	// Unknown genres are reported rather than silently matching nothing, by the export
+	// too.
+	for _, path := range []string{"/v1/movies?genres=nope", "/v1/movies/export?genres=nope"} {
+		status, body := ts.do(t, http.MethodGet, path, "", nil)
+		if status != http.StatusUnprocessableEntity || errorFor(body, "genres") == "" {
+			t.Errorf("%s: status %d, body %v; want 422 with a genres error", path, status, body)
+		}
+	}
+}
+
+func TestListMoviesFacets(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	for _, body := range []string{
+		`{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": ["sci-fi", "horror"]}`,
+		`{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": ["crime", "drama"]}`,
+		`{"title": "Gattaca", "year": 1997, "runtime": "106 mins", "genres": ["sci-fi", "drama"]}`,
+		`{"title": "Airplane!", "year": 1980, "runtime": "88 mins", "genres": ["comedy"]}`,
+	} {
+		if status, res := ts.do(t, http.MethodPost, "/v1/movies", body, nil); status != http.StatusCreated {
+			t.Fatalf("create movie: status %d, body %v", status, res)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  map[string]string
+	}{
+		{
+			"everything",
+			"?facets=genres,decades,runtimes",
+			map[string]string{
+				"genres":   "drama=2 sci-fi=2 comedy=1 crime=1 horror=1",
+				"decades":  "1970s=1 1980s=1 1990s=2",
+				"runtimes": "0-89=1 90-119=2 150+=1",
+			},
+		},
+		{
+			"filtered",
+			"?facets=genres,decades&genres=sci-fi",
+			map[string]string{
+				"genres":  "sci-fi=2 drama=1 horror=1",
+				"decades": "1970s=1 1990s=1",
+			},
+		},
+		{
+			"nothing matched",
+			"?facets=genres&year_min=2000",
+			map[string]string{"genres": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The counts cover every matching movie, not just the ones on the page.
+			status, body := ts.do(t, http.MethodGet, "/v1/movies"+tt.query+"&page_size=1", "", nil)
+			if status != http.StatusOK {
+				t.Fatalf("status %d, body %v", status, body)
+			}
+
+			facets := body["facets"].(map[string]interface{})
+			if len(facets) != len(tt.want) {
+				t.Errorf("got facets %v, want %v", facets, tt.want)
+			}
+
+			for name, want := range tt.want {
+				var counts []string
+				for _, count := range facets[name].([]interface{}) {
+					count := count.(map[string]interface{})
+					counts = append(counts, fmt.Sprintf("%s=%v", count["value"], count["count"]))
+				}
+
+				if got := strings.Join(counts, " "); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+
+	status, body := ts.do(t, http.MethodGet, "/v1/movies?facets=ratings", "", nil)
+	if status != http.StatusUnprocessableEntity || errorFor(body, "facets") == "" {
+		t.Errorf("unknown facet: status %d, body %v; want 422 with a facets error", status, body)
+	}
+}
+
+func TestShowMovieIfNoneMatch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+
+	id := createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	movie, err := app.models.Movies.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := movieETag(movie)
+
+	status, raw := ts.doRaw(t, http.MethodGet, path, "", map[string]string{"If-None-Match": etag})
+	if status != http.StatusNotModified || len(raw) != 0 {
+		t.Errorf("current ETag: status %d, body %q; want an empty 304", status, raw)
+	}
+
+	// Once the movie changes, the old tag no longer matches and the new movie is sent.
+	status, _ = ts.do(t, http.MethodPatch, path, `{"title": "Aliens"}`, nil)
+	if status != http.StatusOK {
+		t.Fatalf("update: status %d, want 200", status)
+	}
+
+	status, body := ts.do(t, http.MethodGet, path, "", map[string]string{"If-None-Match": etag})
+	if status != http.StatusOK || body["movie"].(map[string]interface{})["title"] != "Aliens" {
+		t.Errorf("stale ETag: status %d, body %v; want the updated movie", status, body)
+	}
+}
+
+func TestDeleteMovieIfMatch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+
+	id := createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	movie, err := app.models.Movies.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := movieETag(movie)
+
+	// Someone else updates the movie, so the tag held by the client is stale.
+	movie.Title = "Aliens"
+	if err := app.models.Movies.Update(context.Background(), movie); err != nil {
+		t.Fatal(err)
+	}
+
+	status, _ := ts.do(t, http.MethodDelete, path, "", map[string]string{"If-Match": etag})
+	if status != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: status %d, want 412", status)
+	}
+
+	status, _ = ts.do(t, http.MethodDelete, path, "", map[string]string{"If-Match": movieETag(movie)})
+	if status != http.StatusOK {
+		t.Errorf("current If-Match: status %d, want 200", status)
+	}
+
+	status, _ = ts.do(t, http.MethodDelete, path, "", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("trashed movie: status %d, want 404", status)
+	}
+}
+
+func TestCreateMovieValidation(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	tests := []struct {
+		name string
+		body string
+		key  string
+	}{
+		{"missing title", `{"year": 1979, "runtime": "117 mins", "genres": ["drama"]}`, "title"},
+		{"future year", `{"title": "Alien", "year": 3000, "runtime": "117 mins", "genres": ["drama"]}`, "year"},
+		{"duplicate genres", `{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": ["drama", "drama"]}`, "genres"},
+		{"unknown genre", `{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": ["nope"]}`, "genres"},
+		{"same genre by two names", `{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": ["sf", "Sci-Fi"]}`, "genres"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ts.do(t, http.MethodPost, "/v1/movies", tt.body, nil)
+			if status != http.StatusUnprocessableEntity || errorFor(body, tt.key) == "" {
+				t.Errorf("status %d, body %v; want 422 with a %s error", status, body, tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"strings"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.abhishek/internal/blob"
+	"greenlight.abhishek/internal/data"
+	"greenlight.abhishek/internal/validator"
+)
+
+// maxPosterBytes is the maximum size of an uploaded poster image. The request body may
+// be a little larger, to make room for the multipart headers and boundaries.
+const maxPosterBytes = 10 << 20
+
+// posterExtensions maps the content types which posters can be uploaded in to the file
+// extension used for the stored original.
+var posterExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// uploadPosterHandler replaces the poster of a movie with the image in the "poster"
+// field of a multipart/form-data request body. The original image is stored along with
+// a JPEG thumbnail for each of data.PosterSizes, and the images of the previous poster
+// are deleted.
+func (app *application) uploadPosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// If-Match works the same way as for updateMovieHandler.
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !etagMatches(ifMatch, movieETag(movie), true) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	upload, err := app.readPosterUpload(w, r)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.payloadTooLargeResponse(w, r, maxPosterBytes)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	v.Check(upload != nil, "poster", "must be provided")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Sniff the content type rather than trusting the one sent by the client, and read
+	// the dimensions from the image header before committing to decoding all of it.
+	contentType := http.DetectContentType(upload)
+
+	var width, height int
+	if config, _, err := image.DecodeConfig(bytes.NewReader(upload)); err == nil {
+		width, height = config.Width, config.Height
+	}
+
+	if data.ValidatePosterImage(v, contentType, width, height); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(upload))
+	if err != nil {
+		v.AddError("poster", "must be a valid image")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	poster, err := app.storePoster(r, movie.ID, upload, contentType, img)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	previous := movie.Poster
+	movie.Poster = poster
+
+	err = app.models.Movies.UpdatePoster(r.Context(), movie)
+	if err != nil {
+		// The new images are unused if the poster couldn't be saved.
+		app.background(context.WithoutCancel(r.Context()), func(ctx context.Context) {
+			app.deleteBlobs(ctx, poster.Keys())
+		})
+
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && ifMatch != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.background(context.WithoutCancel(r.Context()), func(ctx context.Context) {
+		app.deleteBlobs(ctx, previous.Keys())
+	})
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readPosterUpload reads the contents of the "poster" field of a multipart/form-data
+// request body. It returns nil if there is no such field.
+func (app *application) readPosterUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPosterBytes+64<<10)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("body must be a multipart/form-data request")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() != "poster" {
+			part.Close()
+			continue
+		}
+
+		defer part.Close()
+
+		// Read one byte more than allowed, to tell a file of exactly the maximum size
+		// apart from a larger one.
+		upload, err := io.ReadAll(io.LimitReader(part, maxPosterBytes+1))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(upload) > maxPosterBytes {
+			return nil, &http.MaxBytesError{Limit: maxPosterBytes}
+		}
+
+		return upload, nil
+	}
+}
+
+// storePoster saves the original upload and its thumbnails in blob storage. Every
+// poster gets keys with a new random token, so that stored images never change once
+// they have been served, and the previous poster's images can be deleted separately.
+func (app *application) storePoster(r *http.Request, movieID int64, upload []byte, contentType string, img image.Image) (data.Poster, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("posters/%d/%s", movieID, hex.EncodeToString(b))
+
+	poster := make(data.Poster, len(data.PosterSizes)+1)
+
+	put := func(name string, content []byte, contentType, extension string, width, height int) error {
+		key := prefix + "/" + name + extension
+
+		err := app.blobs.Put(r.Context(), key, bytes.NewReader(content), contentType)
+		if err != nil {
+			return err
+		}
+
+		poster[name] = data.PosterImage{Key: key, URL: app.blobs.URL(key), Width: width, Height: height}
+		return nil
+	}
+
+	bounds := img.Bounds()
+	flat := flattenImage(img)
+
+	err := put(data.PosterOriginal, upload, contentType, posterExtensions[contentType], bounds.Dx(), bounds.Dy())
+	if err == nil {
+		for _, size := range data.PosterSizes {
+			thumbnail := resizeImage(flat, size.Width)
+
+			var buf bytes.Buffer
+			if err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 85}); err != nil {
+				break
+			}
+
+			tb := thumbnail.Bounds()
+			if err = put(size.Name, buf.Bytes(), "image/jpeg", ".jpg", tb.Dx(), tb.Dy()); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		app.deleteBlobs(context.WithoutCancel(r.Context()), poster.Keys())
+		return nil, err
+	}
+
+	return poster, nil
+}
+
+// flattenImage converts img to RGBA, filling in any transparent areas with white since
+// the thumbnails are encoded as JPEGs.
+func flattenImage(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+
+	return flat
+}
+
+// resizeImage scales src down to the given width, keeping its aspect ratio, by
+// averaging the block of source pixels behind each pixel of the result. Images which
+// are already narrower are returned as they are.
+func resizeImage(src *image.RGBA, width int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if width >= sw {
+		return src
+	}
+
+	height := (sh*width + sw/2) / sw
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for dy := 0; dy < height; dy++ {
+		y0, y1 := dy*sh/height, (dy+1)*sh/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+
+		for dx := 0; dx < width; dx++ {
+			x0, x1 := dx*sw/width, (dx+1)*sw/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+
+			n := (x1 - x0) * (y1 - y0)
+			offset := dy*dst.Stride + dx*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+
+	return dst
+}
+
+// showFileHandler serves files from blob storage, such as the poster images, when they
+// are not served from anywhere else. Each file is stored under a unique key and never
+// changes, so it can be cached forever.
+func (app *application) showFileHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName("key"), "/")
+
+	object, err := app.blobs.Open(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer object.Close()
+
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	http.ServeContent(w, r, key, object.ModTime, object)
+}
+
+// deleteBlobs deletes stored objects which are no longer needed. It usually runs in the
+// background after the response has been decided, so failures are only logged, leaving
+// the objects behind. The context should not be cancelled along with the request, but
+// carries its details for the logs.
+func (app *application) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		err := app.blobs.Delete(ctx, key)
+		if err != nil && !errors.Is(err, blob.ErrNotFound) {
+			app.logger.ErrorContext(ctx, "deleting blob", "key", key, "error", err.Error())
+		}
+	}
+}
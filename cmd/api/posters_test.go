@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+func TestResizeImage(t *testing.T) {
+	// A 400x600 image, black on the left half and white on the right.
+	src := image.NewRGBA(image.Rect(0, 0, 400, 600))
+	for y := 0; y < 600; y++ {
+		for x := 200; x < 400; x++ {
+			src.Set(x, y, color.White)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		width        int
+		wantW, wantH int
+	}{
+		{"half", 200, 200, 300},
+		{"uneven", 185, 185, 278},
+		{"tiny", 1, 1, 2},
+		{"same width", 400, 400, 600},
+		{"wider", 500, 400, 600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := resizeImage(src, tt.width)
+
+			if b := dst.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Fatalf("resizeImage() is %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+
+	// Each pixel is the average of the block behind it, so the halves stay black and
+	// white, and a pixel straddling both is grey.
+	dst := resizeImage(src, 200)
+	if got := dst.RGBAAt(0, 0); got != (color.RGBA{0, 0, 0, 0}) {
+		t.Errorf("left pixel = %v, want transparent black", got)
+	}
+	if got := dst.RGBAAt(199, 299); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("right pixel = %v, want white", got)
+	}
+
+	grey := resizeImage(src, 1).RGBAAt(0, 0)
+	if grey.R != 127 || grey.A != 127 {
+		t.Errorf("single pixel = %v, want the average of both halves", grey)
+	}
+}
+
+func TestFlattenImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(10, 10, 12, 12))
+	src.Set(10, 10, color.NRGBA{255, 0, 0, 255})
+
+	flat := flattenImage(src)
+
+	if b := flat.Bounds(); b != image.Rect(0, 0, 2, 2) {
+		t.Fatalf("flattenImage() bounds = %v, want 2x2 at the origin", b)
+	}
+	if got := flat.RGBAAt(0, 0); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("opaque pixel = %v, want red", got)
+	}
+	if got := flat.RGBAAt(1, 1); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("transparent pixel = %v, want white", got)
+	}
+}
+
+// posterUpload returns a multipart/form-data body holding a PNG of the given size in
+// its poster field, and the Content-Type header to send it with.
+func posterUpload(t *testing.T, width, height int) (string, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	part, err := mw.CreateFormFile("poster", "poster.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(part, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return body.String(), mw.FormDataContentType()
+}
+
+func TestUploadPoster(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	id := createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+	path := fmt.Sprintf("/v1/movies/%d/poster", id)
+
+	upload := func(width, height int) (int, map[string]interface{}) {
+		body, contentType := posterUpload(t, width, height)
+		return ts.do(t, http.MethodPut, path, body, map[string]string{"Content-Type": contentType})
+	}
+
+	status, body := upload(400, 600)
+	if status != http.StatusOK {
+		t.Fatalf("upload: status %d, body %v", status, body)
+	}
+
+	poster := body["movie"].(map[string]interface{})["poster"].(map[string]interface{})
+
+	// The original is kept as it is, and each thumbnail follows its aspect ratio.
+	want := map[string]string{"original": "400x600", "small": "185x278", "medium": "400x600"}
+	for name, size := range want {
+		image := poster[name].(map[string]interface{})
+		if got := fmt.Sprintf("%vx%v", image["width"], image["height"]); got != size {
+			t.Errorf("%s is %s, want %s", name, got, size)
+		}
+	}
+
+	small := poster["small"].(map[string]interface{})["url"].(string)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+small, nil)
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "image/jpeg" {
+		t.Errorf("thumbnail: status %d, Content-Type %q; want a JPEG", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	// A new poster replaces the old one, whose images are deleted.
+	if status, body := upload(200, 300); status != http.StatusOK {
+		t.Fatalf("second upload: status %d, body %v", status, body)
+	}
+
+	if status, _ := ts.doRaw(t, http.MethodGet, small, "", nil); status != http.StatusNotFound {
+		t.Errorf("previous thumbnail: status %d, want 404", status)
+	}
+
+	status, body = upload(50, 50)
+	if status != http.StatusUnprocessableEntity || errorFor(body, "poster") == "" {
+		t.Errorf("tiny poster: status %d, body %v; want 422 with a poster error", status, body)
+	}
+}
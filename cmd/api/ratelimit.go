@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket allows a burst of requests, refilling at a steady rate after that. The
+// bucket is refilled lazily, when a request arrives, so an idle bucket costs nothing.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens which have accrued since the bucket was last used.
+func (b *tokenBucket) refill(now time.Time, rps float64, burst int) {
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rps)
+	b.last = now
+}
+
+// until returns how long it will take for the bucket to hold n tokens.
+func (b *tokenBucket) until(n float64, rps float64) time.Duration {
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / rps * float64(time.Second))
+}
+
+// rateLimiter keeps a token bucket for each client IP address.
+type rateLimiter struct {
+	rps   float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rps:     rps,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// rateLimitResult describes the state of a client's bucket after a request.
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration // Until the next request would be allowed
+	reset      time.Duration // Until the bucket is full again
+}
+
+// allow takes a token from the client's bucket, if it has one.
+func (l *rateLimiter) allow(client string, now time.Time) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.refill(now, l.rps, l.burst)
+
+	result := rateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.allowed = true
+	}
+
+	result.remaining = int(bucket.tokens)
+	result.retryAfter = bucket.until(1, l.rps)
+	result.reset = bucket.until(float64(l.burst), l.rps)
+
+	return result
+}
+
+// cleanup forgets the clients whose buckets have refilled completely, since a full
+// bucket is no different from the new one they would get on their next request.
+func (l *rateLimiter) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for client, bucket := range l.buckets {
+		bucket.refill(now, l.rps, l.burst)
+		if bucket.tokens >= float64(l.burst) {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// The rateLimit() middleware limits the rate of requests from each client IP address
+// with a token bucket, responding with a 429 Too Many Requests once a client has used
+// up its burst. The state of the client's bucket is reported in the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers of every response.
+//
+// The returned middleware shares a single set of buckets between all of the routes it
+// is used for.
+func (app *application) rateLimit() middleware {
+	cfg := app.config.limiter
+
+	if !cfg.enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	limiter := newRateLimiter(cfg.rps, cfg.burst)
+
+	// Forget idle clients once a minute, so that the map of buckets doesn't grow
+	// forever, until the server shuts down.
+	app.background(context.Background(), func(ctx context.Context) {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				limiter.cleanup(now)
+			case <-app.shutdown:
+				return
+			}
+		}
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, cfg.trustedProxies)
+
+			result := limiter.allow(ip, time.Now())
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(cfg.burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+
+			if !result.allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.retryAfter)))
+				app.rateLimitExceededResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds rounds d up to a whole number of seconds, for use in headers.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// clientIP identifies the client which made the request, by its IP address. When the
+// request comes from one of the trusted proxies, the address is taken from the
+// X-Forwarded-For header instead, skipping over any further trusted proxies from the
+// right, or failing that from the X-Real-IP header. Addresses in the headers sent by
+// anyone else are ignored, since clients can put whatever they like there.
+//
+// A malformed address where the client's should be is returned as it is, rather than
+// falling back to a trusted proxy: that would put every client behind the proxy in the
+// same bucket.
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	remote = remote.Unmap()
+
+	if !trusted(remote, trustedProxies) {
+		return remote.String()
+	}
+
+	// Each proxy appends the address it received the request from, so the rightmost
+	// address which isn't a trusted proxy is the client.
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	if len(forwarded) == 0 {
+		realIP := strings.TrimSpace(r.Header.Get("X-Real-IP"))
+		if realIP == "" {
+			return remote.String()
+		}
+
+		if addr, err := netip.ParseAddr(realIP); err == nil {
+			return addr.Unmap().String()
+		}
+		return realIP
+	}
+
+	var client netip.Addr
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		entry := strings.TrimSpace(forwarded[i])
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return entry
+		}
+
+		client = addr.Unmap()
+		if !trusted(client, trustedProxies) {
+			break
+		}
+	}
+
+	// If every address is a trusted proxy, the leftmost one made the request.
+	return client.String()
+}
+
+// trusted reports whether addr is within any of the prefixes.
+func trusted(addr netip.Addr, prefixes []netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefixes parses a comma separated list of IP addresses and CIDR ranges. A bare
+// address is treated as a range containing only itself.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if strings.Contains(field, "/") {
+			prefix, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"10.0.0.1", []string{"10.0.0.1/32"}, false},
+		{"10.1.2.3/8, 192.168.0.0/16", []string{"10.0.0.0/8", "192.168.0.0/16"}, false},
+		{"::1,,2001:db8::/32", []string{"::1/128", "2001:db8::/32"}, false},
+		{"::ffff:10.0.0.1", []string{"10.0.0.1/32"}, false},
+		{"nope", nil, true},
+		{"10.0.0.0/33", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			prefixes, err := parsePrefixes(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePrefixes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(prefixes) != len(tt.want) {
+				t.Fatalf("parsePrefixes() = %v, want %v", prefixes, tt.want)
+			}
+			for i, prefix := range prefixes {
+				if prefix.String() != tt.want[i] {
+					t.Errorf("parsePrefixes() = %v, want %v", prefixes, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parsePrefixes("10.0.0.0/8, 127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{"direct", "203.0.113.9:1234", nil, "", "203.0.113.9"},
+		{"untrusted proxy is ignored", "203.0.113.9:1234", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.9"},
+		{"trusted proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"spoofed entry before the client", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:1234", []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"}, "", "198.51.100.1"},
+		{"all trusted", "10.0.0.1:1234", []string{"10.0.0.2"}, "", "10.0.0.2"},
+		{"invalid entry", "10.0.0.1:1234", []string{"198.51.100.1, nope"}, "", "nope"},
+		{"invalid entry behind a trusted proxy", "10.0.0.1:1234", []string{"nope, 10.0.0.2"}, "", "nope"},
+		{"invalid entry before the client", "10.0.0.1:1234", []string{"nope, 198.51.100.1"}, "", "198.51.100.1"},
+		{"real IP", "127.0.0.1:1234", nil, "198.51.100.1", "198.51.100.1"},
+		{"invalid real IP", "127.0.0.1:1234", nil, "nope", "nope"},
+		{"proxy itself", "127.0.0.1:1234", nil, "", "127.0.0.1"},
+		{"IPv4-mapped", "[::ffff:203.0.113.9]:1234", nil, "", "203.0.113.9"},
+		{"IPv6", "[2001:db8::1]:1234", nil, "", "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := clientIP(r, trusted); got != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := newRateLimiter(2, 3)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The burst is allowed straight away, and then the client has to wait.
+	for i := 0; i < 3; i++ {
+		if result := limiter.allow("a", now); !result.allowed || result.remaining != 2-i {
+			t.Fatalf("request %d: %+v, want allowed with %d remaining", i+1, result, 2-i)
+		}
+	}
+
+	result := limiter.allow("a", now)
+	if result.allowed {
+		t.Fatalf("request after the burst was allowed")
+	}
+	if result.retryAfter != 500*time.Millisecond || result.reset != 1500*time.Millisecond {
+		t.Errorf("retryAfter = %s, reset = %s; want 500ms and 1.5s", result.retryAfter, result.reset)
+	}
+
+	// Other clients have their own buckets.
+	if result := limiter.allow("b", now); !result.allowed {
+		t.Errorf("request from another client was denied")
+	}
+
+	// Tokens come back at the steady rate.
+	if result := limiter.allow("a", now.Add(500*time.Millisecond)); !result.allowed {
+		t.Errorf("request after the bucket refilled was denied")
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	limiter := newRateLimiter(1, 2)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	limiter.allow("a", now)
+	limiter.allow("b", now)
+	limiter.allow("b", now)
+
+	// After a second, a's bucket is full again but b's isn't.
+	limiter.cleanup(now.Add(time.Second))
+
+	if _, ok := limiter.buckets["a"]; ok {
+		t.Errorf("idle client a wasn't removed")
+	}
+	if _, ok := limiter.buckets["b"]; !ok {
+		t.Errorf("client b was removed before its bucket refilled")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 1
+	app.config.limiter.burst = 2
+
+	handler := app.routes()
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = "203.0.113.9:1234"
+		handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := serve("/v1/genres"); rr.Code != 200 || rr.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: status %d, headers %v", i+1, rr.Code, rr.Header())
+		}
+	}
+
+	rr := serve("/v1/genres")
+	if rr.Code != 429 || rr.Header().Get("Retry-After") != "1" || rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("status %d, headers %v; want 429 with Retry-After", rr.Code, rr.Header())
+	}
+
+	// The healthcheck isn't rate limited.
+	if rr := serve("/v1/healthcheck"); rr.Code != 200 {
+		t.Errorf("healthcheck: status %d, want 200", rr.Code)
+	}
+}
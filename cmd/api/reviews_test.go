@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// createTestReview adds a review to a movie through the API and returns its path.
+func createTestReview(t *testing.T, ts *testServer, movieID int64, score int) string {
+	t.Helper()
+
+	status, res := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/reviews", movieID), fmt.Sprintf(`{"score": %d, "body": "Tense."}`, score), nil)
+	if status != http.StatusCreated {
+		t.Fatalf("create review: status %d, body %v", status, res)
+	}
+
+	id := int64(res["review"].(map[string]interface{})["id"].(float64))
+	return fmt.Sprintf("/v1/movies/%d/reviews/%d", movieID, id)
+}
+
+func TestReviewRatings(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	alien := createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+	heat := createTestMovie(t, ts, "Heat", `["crime"]`)
+
+	// rating fetches the movie's aggregated rating and the number of reviews behind it.
+	rating := func(id int64) (float64, float64) {
+		t.Helper()
+
+		status, body := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", id), "", nil)
+		if status != http.StatusOK {
+			t.Fatalf("show movie: status %d, body %v", status, body)
+		}
+
+		movie := body["movie"].(map[string]interface{})
+		return movie["rating"].(float64), movie["rating_count"].(float64)
+	}
+
+	if r, n := rating(alien); r != 0 || n != 0 {
+		t.Errorf("unreviewed: rating %v from %v reviews, want 0 from 0", r, n)
+	}
+
+	first := createTestReview(t, ts, alien, 8)
+	second := createTestReview(t, ts, alien, 6)
+	createTestReview(t, ts, heat, 7)
+
+	if r, n := rating(alien); r != 7 || n != 2 {
+		t.Errorf("after two reviews: rating %v from %v reviews, want 7 from 2", r, n)
+	}
+
+	// Changing a score moves the average, but not the count.
+	if status, body := ts.do(t, http.MethodPatch, second, `{"score": 10}`, nil); status != http.StatusOK {
+		t.Fatalf("update review: status %d, body %v", status, body)
+	}
+
+	if r, n := rating(alien); r != 9 || n != 2 {
+		t.Errorf("after an update: rating %v from %v reviews, want 9 from 2", r, n)
+	}
+
+	status, body := ts.do(t, http.MethodGet, "/v1/movies?sort=-rating", "", nil)
+	if ids := movieIDs(body); status != http.StatusOK || !slices.Equal(ids, []int64{alien, heat}) {
+		t.Errorf("sorted by rating: status %d, got movies %v; want [%d %d]", status, ids, alien, heat)
+	}
+
+	if status, body := ts.do(t, http.MethodDelete, first, "", nil); status != http.StatusOK {
+		t.Fatalf("delete review: status %d, body %v", status, body)
+	}
+
+	if r, n := rating(alien); r != 10 || n != 1 {
+		t.Errorf("after a delete: rating %v from %v reviews, want 10 from 1", r, n)
+	}
+
+	if status, body := ts.do(t, http.MethodDelete, second, "", nil); status != http.StatusOK {
+		t.Fatalf("delete review: status %d, body %v", status, body)
+	}
+
+	if r, n := rating(alien); r != 0 || n != 0 {
+		t.Errorf("after deleting every review: rating %v from %v reviews, want 0 from 0", r, n)
+	}
+}
+
+func TestUpdateReviewTrashedMovie(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	id := createTestMovie(t, ts, "Alien", `["sci-fi"]`)
+	path := createTestReview(t, ts, id, 8)
+
+	status, _ := ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", id), "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("delete movie: status %d, want 200", status)
+	}
+
+	status, _ = ts.do(t, http.MethodPatch, path, `{"score": 10}`, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("update after the movie was trashed: status %d, want 404", status)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
-func (app *application) routes() *httprouter.Router {
+func (app *application) routes() http.Handler {
 	// Initialize a new httprouter instance.
 	router := httprouter.New()
 
@@ -20,14 +20,79 @@ func (app *application) routes() *httprouter.Router {
 	// example route /foo/ will redirect to /foo.
 	router.RedirectTrailingSlash = true
 
+	// Routes are registered in groups, each with its own middleware. Reads may be cached
+	// but must be revalidated, which the ETags on movies make cheap, while the responses
+	// to writes must never be stored.
+	//
+	// Everything but the healthcheck is rate limited, so that load balancers polling it
+	// are never turned away.
+	routes := &routeGroup{router: router}
+	limited := routes.group(app.rateLimit())
+	reads := limited.group(app.cacheControl("no-cache"))
+	writes := limited.group(app.cacheControl("no-store"))
+
 	// registering routes
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updateMovieHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
-
-	// Return the httprouter instance.
-	return router
+	routes.handle(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+
+	reads.handle(http.MethodGet, "/v1/movies", app.listMoviesHandler)
+	reads.handle(http.MethodGet, "/v1/movies/:id", dispatchParam("id", "export", app.exportMoviesHandler, app.showMovieHandler))
+	reads.handle(http.MethodGet, "/v1/movies/:id/history", app.listMovieRevisionsHandler)
+	reads.handle(http.MethodGet, "/v1/movies/:id/history/:version", app.showMovieRevisionHandler)
+	reads.handle(http.MethodGet, "/v1/movies/:id/credits", app.listMovieCreditsHandler)
+	reads.handle(http.MethodGet, "/v1/movies/:id/reviews", app.listReviewsHandler)
+	reads.handle(http.MethodGet, "/v1/movies/:id/reviews/:review_id", app.showReviewHandler)
+	reads.handle(http.MethodGet, "/v1/genres", app.listGenresHandler)
+	reads.handle(http.MethodGet, "/v1/genres/:slug", app.showGenreHandler)
+	reads.handle(http.MethodGet, "/v1/people", app.listPeopleHandler)
+	reads.handle(http.MethodGet, "/v1/people/:id", app.showPersonHandler)
+
+	// Uploaded files, for when they are served by the API itself.
+	reads.handle(http.MethodGet, "/v1/files/*key", app.showFileHandler)
+
+	writes.handle(http.MethodPost, "/v1/movies", app.createMovieHandler)
+	writes.handle(http.MethodPost, "/v1/movies/:id", dispatchParam("id", "bulk", app.bulkCreateMoviesHandler, app.methodNotAllowedResponse))
+	writes.handle(http.MethodPatch, "/v1/movies/:id", app.updateMovieHandler)
+	writes.handle(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
+	writes.handle(http.MethodPost, "/v1/movies/:id/restore", app.restoreMovieHandler)
+	writes.handle(http.MethodPost, "/v1/movies/:id/revert", app.revertMovieHandler)
+	writes.handle(http.MethodPut, "/v1/movies/:id/credits", app.replaceMovieCreditsHandler)
+	writes.handle(http.MethodPost, "/v1/movies/:id/reviews", app.createReviewHandler)
+	writes.handle(http.MethodPatch, "/v1/movies/:id/reviews/:review_id", app.updateReviewHandler)
+	writes.handle(http.MethodDelete, "/v1/movies/:id/reviews/:review_id", app.deleteReviewHandler)
+	writes.handle(http.MethodPut, "/v1/movies/:id/poster", app.uploadPosterHandler)
+	writes.handle(http.MethodPost, "/v1/genres", app.createGenreHandler)
+	writes.handle(http.MethodPatch, "/v1/genres/:slug", app.updateGenreHandler)
+	writes.handle(http.MethodDelete, "/v1/genres/:slug", app.deleteGenreHandler)
+	writes.handle(http.MethodPost, "/v1/people", app.createPersonHandler)
+	writes.handle(http.MethodPatch, "/v1/people/:id", app.updatePersonHandler)
+	writes.handle(http.MethodDelete, "/v1/people/:id", app.deletePersonHandler)
+
+	// admin routes for managing trashed movies. The trash is never cached.
+	admin := limited.group(app.cacheControl("no-store"))
+	admin.handle(http.MethodGet, "/v1/admin/trash/movies", app.listTrashedMoviesHandler)
+	admin.handle(http.MethodDelete, "/v1/admin/trash/movies/:id", app.purgeMovieHandler)
+
+	// Wrap the router with the middleware which applies to every request. The requestID
+	// middleware comes first, so that every request carries an ID for the rest of its
+	// lifetime, including when recoverPanic reports a panic. logRequest goes outside
+	// recoverPanic so that it sees the 500 response written after a panic. enableCORS
+	// answers preflight requests before they reach the router, and before they could be
+	// rate limited.
+	return chain(router, app.requestID, app.logRequest, app.recoverPanic, app.enableCORS)
+}
+
+// dispatchParam() returns a handler which calls match when the named URL parameter is
+// equal to value, and fallback otherwise. httprouter doesn't allow a static path
+// segment in the same position as a named parameter, so routes such as
+// GET /v1/movies/export have to be registered as GET /v1/movies/:id and told apart
+// here.
+func dispatchParam(name, value string, match, fallback http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if httprouter.ParamsFromContext(r.Context()).ByName(name) == value {
+			match(w, r)
+			return
+		}
+
+		fallback(w, r)
+	}
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve runs the HTTP server until the process receives SIGINT or SIGTERM, then shuts
+// it down gracefully: the healthcheck starts reporting that the server is draining,
+// in-flight requests are given the grace period to complete, and so are any tasks
+// started with app.background().
+func (app *application) serve() error {
+	// Configure the HTTP server with address, handlers, and timeout settings
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port), // Set server address using the configured port
+		Handler:      app.routes(),                        // Set the HTTP request handler
+		IdleTimeout:  time.Minute,                         // Set idle timeout duration
+		ReadTimeout:  10 * time.Second,                    // Set read timeout duration
+		WriteTimeout: 30 * time.Second,                    // Set write timeout duration
+		// Send the server's own error messages through the structured logger too.
+		ErrorLog: slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
+
+	// Receives the outcome of the shutdown once it is complete.
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+		s := <-quit
+
+		// Fail the healthcheck, and give load balancers time to notice before the
+		// listener is closed.
+		app.draining.Store(true)
+		close(app.shutdown)
+		app.logger.Info("caught signal, draining", "signal", s.String(), "drain_delay", app.config.shutdown.drainDelay.String())
+		time.Sleep(app.config.shutdown.drainDelay)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdown.gracePeriod)
+		defer cancel()
+
+		// Shutdown() stops accepting new connections and returns once every in-flight
+		// request has completed, or with an error when ctx expires first. Either way the
+		// background tasks still get whatever is left of the grace period, so the error
+		// is only reported after waiting for them.
+		app.logger.Info("shutting down server")
+		err := srv.Shutdown(ctx)
+
+		app.logger.Info("completing background tasks")
+
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = errors.Join(err, errors.New("background tasks did not complete within the grace period"))
+		}
+
+		shutdownError <- err
+	}()
+
+	// Start the HTTP server and log the environment and address details
+	app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.env)
+
+	// ListenAndServe() returns http.ErrServerClosed as soon as Shutdown() is called, so
+	// that isn't an error. The outcome of the shutdown itself comes from the goroutine.
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-shutdownError; err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server", "addr", srv.Addr)
+
+	return nil
+}
+
+// The background() helper runs fn in a goroutine which the graceful shutdown waits
+// for. fn is passed ctx, which should not be cancelled when the request that started
+// the task ends. A panic in fn is logged rather than crashing the whole server.
+func (app *application) background(ctx context.Context, fn func(ctx context.Context)) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.ErrorContext(ctx, fmt.Sprintf("panic in background task: %v", err))
+			}
+		}()
+
+		fn(ctx)
+	}()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.abhishek/internal/blob"
+	"greenlight.abhishek/internal/data"
+)
+
+// newTestApplication returns an application backed by the in-memory models, with
+// logging discarded.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	var cfg config
+	cfg.env = "testing"
+	cfg.cursor.secret = []byte("test-secret")
+	cfg.db.timeouts.export = time.Minute
+
+	blobs, err := blob.NewLocalStore(t.TempDir(), "/v1/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		config:   cfg,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		models:   data.NewMemoryModels(),
+		blobs:    blobs,
+		shutdown: make(chan struct{}),
+	}
+
+	// Stop any background tasks which run until shutdown, such as the rate limiter's
+	// cleanup, and wait for them.
+	t.Cleanup(func() {
+		close(app.shutdown)
+		app.wg.Wait()
+	})
+
+	return app
+}
+
+// testServer wraps an httptest.Server running the application's routes.
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, app *application) *testServer {
+	t.Helper()
+
+	ts := httptest.NewServer(app.routes())
+	t.Cleanup(ts.Close)
+
+	return &testServer{ts}
+}
+
+// do sends a request with an optional JSON body and returns the response status and
+// its decoded JSON body, which is nil if the body is empty.
+func (ts *testServer) do(t *testing.T, method, path, body string, headers map[string]string) (int, map[string]interface{}) {
+	t.Helper()
+
+	status, raw := ts.doRaw(t, method, path, body, headers)
+
+	var decoded map[string]interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q", method, path, raw)
+		}
+	}
+
+	return status, decoded
+}
+
+// doRaw is like do, but returns the response body as it is.
+func (ts *testServer) doRaw(t *testing.T, method, path, body string, headers map[string]string) (int, []byte) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode, raw
+}
+
+// errorFor returns the validation error for key in the body of a 422 response.
+func errorFor(body map[string]interface{}, key string) string {
+	errs, _ := body["error"].(map[string]interface{})
+	message, _ := errs[key].(string)
+	return message
+}
+
+// movieIDs returns the ids of the movies in the body of a list response, in order.
+func movieIDs(body map[string]interface{}) []int64 {
+	var ids []int64
+	for _, movie := range body["movies"].([]interface{}) {
+		ids = append(ids, int64(movie.(map[string]interface{})["id"].(float64)))
+	}
+	return ids
+}
@@ -0,0 +1,44 @@
+// Package blob stores binary objects, such as poster images, outside of the database.
+// Objects are identified by slash-separated keys like "posters/42/1f2e3d4c/original.jpg".
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store is the set of operations supported by every blob storage backend. Keys must be
+// valid io/fs paths, so they can't be empty, start with a slash or contain "." or ".."
+// elements. Open() and Delete() return ErrNotFound for keys which aren't stored. A
+// backend may work out the content type of an object from the extension of its key
+// instead of keeping the one given to Put(), so the two should always agree.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Open(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+
+	// URL returns the address clients can download the object with the given key
+	// from. It may be relative to the API's own address.
+	URL(key string) string
+}
+
+// Object is an open stored object. It must be closed once the caller is done with it.
+type Object struct {
+	io.ReadSeekCloser
+	ContentType string    // MIME type of the object, which may be derived from its key
+	Size        int64     // Size of the object in bytes
+	ModTime     time.Time // Timestamp for when the object was stored
+}
+
+// ValidKey reports whether key can be used to identify an object.
+func ValidKey(key string) bool {
+	return fs.ValidPath(key) && key != "."
+}
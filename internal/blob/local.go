@@ -0,0 +1,151 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore is a Store which keeps each object in a file under a directory on the
+// local filesystem. The content type of an object is worked out from the extension of
+// its key, so keys should always have one.
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStore returns a LocalStore which keeps its files under dir, creating the
+// directory if necessary. The URL of each object is its key appended to baseURL.
+func NewLocalStore(dir, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Put writes the object to a temporary file first and then renames it into place, so
+// that a reader never sees a partially written object.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	name := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+
+	// Remove() fails harmlessly once the file has been renamed.
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *LocalStore) Open(ctx context.Context, key string) (*Object, error) {
+	if !ValidKey(key) {
+		return nil, ErrNotFound
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Directories are an implementation detail of the store, not objects.
+	if info.IsDir() {
+		f.Close()
+		return nil, ErrNotFound
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &Object{
+		ReadSeekCloser: f,
+		ContentType:    contentType,
+		Size:           info.Size(),
+		ModTime:        info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrNotFound
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := os.Remove(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	// Remove the directories which held the object, as long as they are now empty.
+	// os.Remove() refuses to remove a directory with anything left in it.
+	for dir := path.Dir(key); dir != "."; dir = path.Dir(dir) {
+		if os.Remove(s.path(dir)) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// path returns the name of the file holding the object with the given key, which
+// must already have been checked with ValidKey().
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
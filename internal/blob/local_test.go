@@ -0,0 +1,86 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewLocalStore(dir, "/v1/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const key = "posters/42/abcd/original.png"
+
+	if err := store.Put(ctx, key, strings.NewReader("not really a png"), "image/png"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	object, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	contents, err := io.ReadAll(object)
+	object.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "not really a png" || object.Size != int64(len(contents)) {
+		t.Errorf("Open() read %q with size %d", contents, object.Size)
+	}
+	if object.ContentType != "image/png" {
+		t.Errorf("ContentType = %q, want image/png", object.ContentType)
+	}
+
+	if url := store.URL(key); url != "/v1/files/"+key {
+		t.Errorf("URL() = %q", url)
+	}
+
+	// Directories aren't objects.
+	if _, err := store.Open(ctx, "posters/42"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() of a directory error = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() after Delete() error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete() error = %v, want ErrNotFound", err)
+	}
+
+	// The directories which held the object are removed along with it.
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("store directory holds %v, %v; want nothing", entries, err)
+	}
+}
+
+func TestLocalStoreInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewLocalStore(t.TempDir(), "/v1/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", ".", "/posters/1.png", "posters/../1.png", "posters/./1.png"} {
+		if err := store.Put(ctx, key, strings.NewReader(""), "image/png"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) error = %v, want ErrNotFound", key, err)
+		}
+	}
+}
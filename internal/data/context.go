@@ -0,0 +1,20 @@
+package data
+
+import "context"
+
+type contextKey string
+
+const requestIDContextKey = contextKey("request_id")
+
+// ContextWithRequestID returns a copy of the context carrying the ID of the API request
+// being served, so that the changes made while serving it can be attributed to it.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in the context, or the empty
+// string if there isn't one.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
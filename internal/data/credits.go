@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"greenlight.abhishek/internal/validator"
+)
+
+var ErrPersonNotFound = errors.New("person not found")
+
+// CreditRoles lists the roles a person can be credited with on a movie.
+var CreditRoles = []string{"director", "writer", "actor"}
+
+// maxCredits is the maximum number of credits a movie can have.
+const maxCredits = 500
+
+// Credit records that a person worked on a movie in a particular role.
+type Credit struct {
+	PersonID  int64  `json:"person_id"`           // ID of the credited person
+	Name      string `json:"name"`                // Name of the person, filled in when credits are read
+	Role      string `json:"role"`                // One of CreditRoles
+	Character string `json:"character,omitempty"` // Character played, actors only
+	Billing   int    `json:"billing"`             // Position in the credits, starting at 1
+}
+
+func ValidateCredits(v *validator.Validator, credits []*Credit) {
+	v.Check(credits != nil, "credits", "must be provided")
+	v.Check(len(credits) <= maxCredits, "credits", fmt.Sprintf("must not contain more than %d credits", maxCredits))
+
+	seen := make(map[string]bool, len(credits))
+
+	for i, credit := range credits {
+		key := fmt.Sprintf("credits.%d", i)
+
+		// A null in the JSON array decodes to a nil credit.
+		if credit == nil {
+			v.AddError(key, "must be provided")
+			continue
+		}
+
+		v.Check(credit.PersonID > 0, key, "person_id must be a positive integer")
+		v.Check(validator.In(credit.Role, CreditRoles...), key, "role must be one of director, writer or actor")
+		v.Check(credit.Character == "" || credit.Role == "actor", key, "character can only be given for actors")
+		v.Check(len(credit.Character) <= 500, key, "character must not be more than 500 bytes long")
+		v.Check(credit.Billing > 0, key, "billing must be a positive integer")
+
+		id := fmt.Sprintf("%d:%s", credit.PersonID, credit.Role)
+		v.Check(!seen[id], key, "must not credit the same person with the same role twice")
+		seen[id] = true
+	}
+}
+
+type CreditModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// GetForMovie returns the credits of a live movie in billing order.
+func (m CreditModel) GetForMovie(ctx context.Context, movieID int64) ([]*Credit, error) {
+	query := `
+		SELECT c.person_id, p.name, c.role, c.character, c.billing
+		FROM movie_credits c
+		INNER JOIN people p ON p.id = c.person_id
+		WHERE c.movie_id = $1
+		ORDER BY c.billing, c.id`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.List)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	credits := []*Credit{}
+	for rows.Next() {
+		var credit Credit
+
+		err := rows.Scan(&credit.PersonID, &credit.Name, &credit.Role, &credit.Character, &credit.Billing)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return credits, nil
+}
+
+// ReplaceForMovie replaces all of the credits of a live movie in a single transaction,
+// and fills in the names of the credited people. It returns ErrRecordNotFound if the
+// movie doesn't exist, and ErrPersonNotFound if any of the people don't.
+func (m CreditModel) ReplaceForMovie(ctx context.Context, movieID int64, credits []*Credit) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Update)
+	defer cancel()
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		// Lock the movie row, so that it can't be trashed while its credits change.
+		var id int64
+		err := tx.QueryRowContext(ctx, `
+			SELECT id FROM movies WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`,
+			movieID).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM movie_credits WHERE movie_id = $1`, movieID)
+		if err != nil {
+			return err
+		}
+
+		if len(credits) == 0 {
+			return nil
+		}
+
+		values := make([]string, 0, len(credits))
+		args := []interface{}{movieID}
+
+		for i, credit := range credits {
+			n := i*4 + 1
+			values = append(values, fmt.Sprintf("($1, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
+			args = append(args, credit.PersonID, credit.Role, credit.Character, credit.Billing)
+		}
+
+		// Insert the credits and read back the names of the credited people in the
+		// same statement.
+		query := `
+			WITH inserted AS (
+				INSERT INTO movie_credits (movie_id, person_id, role, character, billing)
+				VALUES ` + strings.Join(values, ", ") + `
+				RETURNING person_id
+			)
+			SELECT DISTINCT p.id, p.name
+			FROM inserted
+			INNER JOIN people p ON p.id = inserted.person_id`
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		names := make(map[int64]string)
+		for rows.Next() {
+			var id int64
+			var name string
+
+			if err := rows.Scan(&id, &name); err != nil {
+				return err
+			}
+
+			names[id] = name
+		}
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, credit := range credits {
+			credit.Name = names[credit.PersonID]
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrPersonNotFound
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return contextError(ctx, err)
+		}
+	}
+
+	sortCredits(credits)
+
+	return nil
+}
+
+// sortCredits puts credits into billing order, like GetForMovie() returns them.
+func sortCredits(credits []*Credit) {
+	sort.SliceStable(credits, func(i, j int) bool {
+		return credits[i].Billing < credits[j].Billing
+	})
+}
@@ -0,0 +1,60 @@
+package data
+
+import "context"
+
+// MemoryCreditModel is the in-memory implementation of CreditRepository. The credits
+// are stored in the MemoryMovieModel it is attached to.
+type MemoryCreditModel struct {
+	movies *MemoryMovieModel
+}
+
+func (m MemoryCreditModel) GetForMovie(ctx context.Context, movieID int64) ([]*Credit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	credits := make([]*Credit, 0, len(m.movies.credits[movieID]))
+	for _, credit := range m.movies.credits[movieID] {
+		c := *credit
+		c.Name = m.movies.people[credit.PersonID].Name
+		credits = append(credits, &c)
+	}
+
+	sortCredits(credits)
+
+	return credits, nil
+}
+
+func (m MemoryCreditModel) ReplaceForMovie(ctx context.Context, movieID int64, credits []*Credit) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	movie, ok := m.movies.movies[movieID]
+	if !ok || movie.DeletedAt != nil {
+		return ErrRecordNotFound
+	}
+
+	stored := make([]*Credit, 0, len(credits))
+	for _, credit := range credits {
+		person, ok := m.movies.people[credit.PersonID]
+		if !ok {
+			return ErrPersonNotFound
+		}
+
+		credit.Name = person.Name
+		c := *credit
+		stored = append(stored, &c)
+	}
+
+	m.movies.credits[movieID] = stored
+	sortCredits(credits)
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"testing"
+
+	"greenlight.abhishek/internal/validator"
+)
+
+func TestValidateCredits(t *testing.T) {
+	director := &Credit{PersonID: 1, Role: "director", Billing: 1}
+	actor := &Credit{PersonID: 2, Role: "actor", Character: "Ripley", Billing: 2}
+
+	tests := []struct {
+		name    string
+		credits []*Credit
+		key     string // Key of the expected error, empty if the credits are valid
+	}{
+		{"valid", []*Credit{director, actor}, ""},
+		{"empty", []*Credit{}, ""},
+		{"missing", nil, "credits"},
+		{"null credit", []*Credit{director, nil}, "credits.1"},
+		{"invalid person", []*Credit{{PersonID: 0, Role: "writer", Billing: 1}}, "credits.0"},
+		{"invalid role", []*Credit{{PersonID: 1, Role: "grip", Billing: 1}}, "credits.0"},
+		{"character for a director", []*Credit{{PersonID: 1, Role: "director", Character: "Ripley", Billing: 1}}, "credits.0"},
+		{"invalid billing", []*Credit{{PersonID: 1, Role: "writer"}}, "credits.0"},
+		{"duplicate", []*Credit{director, {PersonID: 1, Role: "director", Billing: 3}}, "credits.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateCredits(v, tt.credits)
+
+			if tt.key == "" {
+				if !v.Valid() {
+					t.Errorf("unexpected errors %v", v.Errors)
+				}
+				return
+			}
+
+			if _, ok := v.Errors[tt.key]; !ok {
+				t.Errorf("errors = %v, want an error for %q", v.Errors, tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"greenlight.abhishek/internal/validator"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	secret := []byte("secret")
+	cursor := Cursor{Sort: "-year", Value: "1999", ID: 42}
+
+	decoded, err := DecodeCursor(cursor.Encode(secret), secret)
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+
+	if *decoded != cursor {
+		t.Errorf("DecodeCursor() = %+v, want %+v", *decoded, cursor)
+	}
+}
+
+func TestDecodeCursorRejectsInvalidTokens(t *testing.T) {
+	secret := []byte("secret")
+	token := Cursor{Sort: "id", Value: "10", ID: 10}.Encode(secret)
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// A payload for a different cursor, signed with the right secret, but paired with
+	// the signature of the original one.
+	forged, _, _ := strings.Cut(Cursor{Sort: "id", Value: "0 OR 1=1", ID: 10}.Encode(secret), ".")
+
+	tests := []struct {
+		name   string
+		token  string
+		secret []byte
+	}{
+		{"empty", "", secret},
+		{"no signature", payload, secret},
+		{"wrong secret", token, []byte("other")},
+		{"tampered payload", forged + "." + signature, secret},
+		{"tampered signature", payload + "." + strings.Repeat("A", len(signature)), secret},
+		{"invalid payload", "!!!." + signCursor("!!!", secret), secret},
+		{"invalid JSON", "bm90LWpzb24." + signCursor("bm90LWpzb24", secret), secret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCursor(tt.token, tt.secret)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestValidateFiltersCursor(t *testing.T) {
+	secret := []byte("secret")
+	valid := Filters{Page: 1, PageSize: 20, Sort: "-year", SortSafelist: []string{"id", "-year"}, CursorSecret: secret}
+
+	tests := []struct {
+		name   string
+		modify func(f *Filters)
+		valid  bool
+	}{
+		{"same sort", func(f *Filters) { f.Cursor = Cursor{Sort: "-year", Value: "1999", ID: 4}.Encode(secret) }, true},
+		{"different sort", func(f *Filters) { f.Cursor = Cursor{Sort: "id", Value: "4", ID: 4}.Encode(secret) }, false},
+		{"with a page", func(f *Filters) { f.Page = 2; f.Cursor = Cursor{Sort: "-year", Value: "1999", ID: 4}.Encode(secret) }, false},
+		{"forged", func(f *Filters) { f.Cursor = Cursor{Sort: "-year", Value: "1999", ID: 4}.Encode([]byte("other")) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := valid
+			tt.modify(&filters)
+
+			v := validator.New()
+			ValidateFilters(v, filters)
+
+			if _, invalid := v.Errors["cursor"]; invalid == tt.valid {
+				t.Errorf("errors = %v, want valid = %v", v.Errors, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"greenlight.abhishek/internal/validator"
+)
+
+// FacetNames lists the facets which can be requested alongside a movie listing.
+var FacetNames = []string{"genres", "decades", "runtimes"}
+
+// runtimeBucket is a range of runtimes, in minutes, counted together by the runtimes
+// facet. A max of zero means the bucket has no upper bound.
+type runtimeBucket struct {
+	label string
+	max   int
+}
+
+// runtimeBuckets must be in ascending order, each starting where the previous one ends.
+var runtimeBuckets = []runtimeBucket{
+	{label: "0-89", max: 89},
+	{label: "90-119", max: 119},
+	{label: "120-149", max: 149},
+	{label: "150+"},
+}
+
+// FacetCount is the number of matching movies which have a given facet value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets maps each requested facet name to its counts. The genres are ordered most
+// common first, the decades (e.g. "1990s") oldest first and the runtime buckets
+// shortest first.
+type Facets map[string][]FacetCount
+
+func ValidateFacets(v *validator.Validator, facets []string) {
+	for _, facet := range facets {
+		v.Check(validator.In(facet, FacetNames...), "facets", "invalid facet value")
+	}
+	v.Check(validator.Unique(facets), "facets", "must not contain duplicate values")
+}
+
+// newFacets returns a Facets with an empty list for each of the requested facets, so
+// that they appear in the JSON even when nothing matched.
+func newFacets(names []string) Facets {
+	facets := make(Facets, len(names))
+
+	for _, name := range names {
+		facets[name] = []FacetCount{}
+	}
+
+	return facets
+}
+
+func (f Facets) add(name, value string, count int) {
+	f[name] = append(f[name], FacetCount{Value: value, Count: count})
+}
+
+// sort puts the counts of each facet into a stable order which is independent of the
+// storage backend.
+func (f Facets) sort() {
+	genres, decades, runtimes := f["genres"], f["decades"], f["runtimes"]
+
+	sort.Slice(genres, func(i, j int) bool {
+		if genres[i].Count != genres[j].Count {
+			return genres[i].Count > genres[j].Count
+		}
+		return genres[i].Value < genres[j].Value
+	})
+
+	// Decades are four digit years followed by "s", so they sort correctly as strings.
+	sort.Slice(decades, func(i, j int) bool {
+		return decades[i].Value < decades[j].Value
+	})
+
+	sort.Slice(runtimes, func(i, j int) bool {
+		return runtimeBucketIndex(runtimes[i].Value) < runtimeBucketIndex(runtimes[j].Value)
+	})
+}
+
+// decade returns the label of the decade a year belongs to.
+func decade(year int32) string {
+	return strconv.Itoa(int(year/10*10)) + "s"
+}
+
+// runtimeBucketLabel returns the label of the bucket a runtime belongs to.
+func runtimeBucketLabel(runtime Runtime) string {
+	for _, bucket := range runtimeBuckets {
+		if bucket.max == 0 || int(runtime) <= bucket.max {
+			return bucket.label
+		}
+	}
+	return ""
+}
+
+func runtimeBucketIndex(label string) int {
+	for i, bucket := range runtimeBuckets {
+		if bucket.label == label {
+			return i
+		}
+	}
+	return len(runtimeBuckets)
+}
+
+// runtimeBucketSQL returns a CASE expression which computes the same labels as
+// runtimeBucketLabel().
+func runtimeBucketSQL() string {
+	var b strings.Builder
+
+	b.WriteString("CASE")
+	for _, bucket := range runtimeBuckets {
+		if bucket.max == 0 {
+			fmt.Fprintf(&b, " ELSE '%s'", bucket.label)
+		} else {
+			fmt.Fprintf(&b, " WHEN runtime <= %d THEN '%s'", bucket.max, bucket.label)
+		}
+	}
+	b.WriteString(" END")
+
+	return b.String()
+}
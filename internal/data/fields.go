@@ -0,0 +1,50 @@
+package data
+
+import (
+	"strings"
+
+	"github.com/lib/pq"
+	"greenlight.abhishek/internal/validator"
+)
+
+// MovieFields lists the movie fields which clients can limit responses to with the
+// fields parameter, in the order they appear in the Movie JSON. Each of them is read
+// from the column of the same name.
+var MovieFields = []string{"id", "created_at", "title", "year", "runtime", "genres", "rating", "rating_count", "poster", "version"}
+
+func ValidateFields(v *validator.Validator, fields []string) {
+	for _, field := range fields {
+		v.Check(validator.In(field, MovieFields...), "fields", "invalid field value")
+	}
+	v.Check(validator.Unique(fields), "fields", "must not contain duplicate values")
+}
+
+// movieColumns returns the select list for reading the given fields of a movie, and the
+// destinations to scan the columns into, in the same order. The id is always read, and
+// every field is if none are given.
+func movieColumns(movie *Movie, fields []string) (string, []interface{}) {
+	dests := map[string]interface{}{
+		"id":           &movie.ID,
+		"created_at":   &movie.CreatedAt,
+		"title":        &movie.Title,
+		"year":         &movie.Year,
+		"runtime":      &movie.Runtime,
+		"genres":       pq.Array(&movie.Genres),
+		"rating":       &movie.Rating,
+		"rating_count": &movie.RatingCount,
+		"poster":       &movie.Poster,
+		"version":      &movie.Version,
+	}
+
+	var columns []string
+	var dest []interface{}
+
+	for _, field := range MovieFields {
+		if len(fields) == 0 || field == "id" || validator.In(field, fields...) {
+			columns = append(columns, field)
+			dest = append(dest, dests[field])
+		}
+	}
+
+	return strings.Join(columns, ", "), dest
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"strconv"
 	"strings"
 
 	"greenlight.abhishek/internal/validator"
@@ -11,6 +12,9 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	Cursor       string   // Opaque keyset cursor; when set, Page is ignored.
+	CursorSecret []byte   // Key used to sign and verify cursors.
+	Fields       []string // Movie fields to read, all of them if empty.
 }
 
 func (f Filters) sortColumn() string {
@@ -24,14 +28,136 @@ func (f Filters) sortColumn() string {
 }
 
 // Return the sort direction ("ASC" or "DESC") depending on the prefix character of the
-// Sort field.
+// Sort field. Relevance is the exception: only the best matches first is useful, so it
+// always sorts in descending order.
 func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+	if strings.HasPrefix(f.Sort, "-") || f.Sort == "relevance" {
 		return "DESC"
 	}
 	return "ASC"
 }
 
+// fields returns the movie fields to read for a page of results: the requested Fields,
+// plus the sort column which the cursor for the next page is built from.
+func (f Filters) fields() []string {
+	if len(f.Fields) == 0 {
+		return nil
+	}
+
+	column := f.sortColumn()
+	if validator.In(column, MovieFields...) && !validator.In(column, f.Fields...) {
+		return append(append([]string{}, f.Fields...), column)
+	}
+
+	return f.Fields
+}
+
+// limit returns the number of records to fetch for a single page.
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+// offset returns the number of records to skip to reach the current page. The
+// multiplication is safe from overflow because ValidateFilters() caps both values.
+func (f Filters) offset() int {
+	if f.Cursor != "" {
+		return 0
+	}
+	return (f.Page - 1) * f.PageSize
+}
+
+// seekOperator returns the comparison operator which selects the rows that come after
+// a cursor position, given the current sort direction.
+func (f Filters) seekOperator() string {
+	if f.sortDirection() == "DESC" {
+		return "<"
+	}
+	return ">"
+}
+
+// cursor decodes and returns the keyset cursor, or nil if offset pagination is in use.
+func (f Filters) cursor() (*Cursor, error) {
+	if f.Cursor == "" {
+		return nil, nil
+	}
+	return DecodeCursor(f.Cursor, f.CursorSecret)
+}
+
+// nextCursor returns a signed cursor positioned on the given movie, which should be the
+// last one on the current page.
+func (f Filters) nextCursor(movie *Movie) string {
+	var value string
+
+	switch f.sortColumn() {
+	case "title":
+		value = movie.Title
+	case "year":
+		value = strconv.FormatInt(int64(movie.Year), 10)
+	case "runtime":
+		value = strconv.FormatInt(int64(movie.Runtime), 10)
+	case "relevance":
+		value = strconv.FormatFloat(movie.relevance(), 'g', -1, 64)
+	case "rating":
+		value = strconv.FormatFloat(movie.Rating, 'g', -1, 64)
+	default:
+		value = strconv.FormatInt(movie.ID, 10)
+	}
+
+	return Cursor{Sort: f.Sort, Value: value, ID: movie.ID}.Encode(f.CursorSecret)
+}
+
+// Metadata holds the pagination details returned alongside a page of records.
+type Metadata struct {
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	Suggestion   string `json:"suggestion,omitempty"` // "Did you mean" title when a search found nothing
+}
+
+// calculateMetadata() works out the pagination metadata for the given total number of
+// matching records, current page and page size. If there are no records an empty
+// Metadata struct is returned, so that every field is omitted from the JSON.
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     (totalRecords + pageSize - 1) / pageSize,
+		TotalRecords: totalRecords,
+	}
+}
+
+// metadata() builds the metadata for a page of movies given the total number of rows
+// that matched the query. In keyset mode the total only counts rows from the cursor
+// onwards, so the page numbers would be meaningless and are omitted. In both modes a
+// next_cursor is included whenever there are more rows after this page, which lets
+// clients switch from offset to keyset pagination at any point.
+func (f Filters) metadata(movies []*Movie, totalRecords int) Metadata {
+	var metadata Metadata
+	var hasMore bool
+
+	if f.Cursor != "" {
+		metadata = Metadata{PageSize: f.PageSize}
+		hasMore = totalRecords > len(movies)
+	} else {
+		metadata = calculateMetadata(totalRecords, f.Page, f.PageSize)
+		hasMore = f.offset()+len(movies) < totalRecords
+	}
+
+	if hasMore && len(movies) > 0 {
+		metadata.NextCursor = f.nextCursor(movies[len(movies)-1])
+	}
+
+	return metadata
+}
+
 func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.Page > 0, "page", "must be greater than zero")
 	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
@@ -39,4 +165,17 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 	// Check that the sort parameter matches a value in the safelist.
 	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	// A cursor carries the sort parameter it was issued for, so it can only be used to
+	// continue walking the same ordering.
+	if f.Cursor != "" {
+		v.Check(f.Page == 1, "cursor", "must not be combined with page")
+
+		cursor, err := f.cursor()
+		if err != nil {
+			v.AddError("cursor", "must be a valid cursor")
+			return
+		}
+		v.Check(cursor.Sort == f.Sort, "cursor", "does not match the sort parameter")
+	}
 }
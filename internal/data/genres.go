@@ -0,0 +1,369 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+	"greenlight.abhishek/internal/validator"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	ErrGenreInUse     = errors.New("genre in use")
+)
+
+// Genre is an entry in the catalogue of genres which movies can be tagged with. Movies
+// store the slug of each of their genres.
+type Genre struct {
+	ID         int64     `json:"id"`          // Unique integer ID for the genre
+	CreatedAt  time.Time `json:"created_at"`  // Timestamp for when the genre was added
+	Slug       string    `json:"slug"`        // Canonical, URL-safe identifier such as "sci-fi"; can't be changed
+	Name       string    `json:"name"`        // Display name such as "Science Fiction"
+	Synonyms   []string  `json:"synonyms"`    // Other names which clients may use for the genre
+	MovieCount int       `json:"movie_count"` // Number of live movies tagged with the genre
+	Version    int32     `json:"version"`     // Incremented each time the genre is updated
+}
+
+// DefaultGenres is the catalogue which new databases start out with. The migration
+// which creates the genres table inserts the same list.
+var DefaultGenres = []*Genre{
+	{Slug: "action", Name: "Action"},
+	{Slug: "adventure", Name: "Adventure"},
+	{Slug: "animation", Name: "Animation", Synonyms: []string{"animated", "cartoon"}},
+	{Slug: "comedy", Name: "Comedy"},
+	{Slug: "crime", Name: "Crime"},
+	{Slug: "documentary", Name: "Documentary"},
+	{Slug: "drama", Name: "Drama"},
+	{Slug: "family", Name: "Family"},
+	{Slug: "fantasy", Name: "Fantasy"},
+	{Slug: "history", Name: "History", Synonyms: []string{"historical"}},
+	{Slug: "horror", Name: "Horror"},
+	{Slug: "music", Name: "Music", Synonyms: []string{"musical"}},
+	{Slug: "mystery", Name: "Mystery"},
+	{Slug: "romance", Name: "Romance"},
+	{Slug: "sci-fi", Name: "Science Fiction", Synonyms: []string{"scifi", "sf"}},
+	{Slug: "thriller", Name: "Thriller"},
+	{Slug: "war", Name: "War"},
+	{Slug: "western", Name: "Western"},
+}
+
+// genreKey normalizes a genre name for comparison: it is lower-cased and every run of
+// characters other than letters and digits becomes a single hyphen, so "Sci-Fi",
+// "sci fi" and "SCI_FI" all give "sci-fi". A valid slug is its own key.
+func genreKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+// GenreCatalogue resolves the genre names sent by clients to canonical slugs.
+type GenreCatalogue struct {
+	slugs      map[string]string
+	collisions []string
+}
+
+// NewGenreCatalogue builds a catalogue from the genres, which should be ordered by slug.
+// ValidateGenre() stops two genres from sharing a name, but if they do anyway, the name
+// is never reassigned: a slug always resolves to its own genre, and a name or synonym
+// shared by two genres resolves to the one which comes first. Each such collision is
+// reported by Collisions().
+func NewGenreCatalogue(genres []*Genre) *GenreCatalogue {
+	c := &GenreCatalogue{slugs: make(map[string]string)}
+
+	claim := func(name, slug string) {
+		key := genreKey(name)
+		if existing, ok := c.slugs[key]; ok {
+			if existing != slug {
+				c.collisions = append(c.collisions, fmt.Sprintf("%q is used by both the %s and %s genres", name, existing, slug))
+			}
+			return
+		}
+		c.slugs[key] = slug
+	}
+
+	for _, genre := range genres {
+		claim(genre.Slug, genre.Slug)
+	}
+
+	for _, genre := range genres {
+		for _, name := range append([]string{genre.Name}, genre.Synonyms...) {
+			claim(name, genre.Slug)
+		}
+	}
+
+	return c
+}
+
+// Collisions describes each name which more than one genre in the catalogue is known
+// by.
+func (c *GenreCatalogue) Collisions() []string {
+	return c.collisions
+}
+
+// logCollisions warns about the names shared by more than one genre, which can only
+// come about through changes made outside of the API.
+func logCollisions(ctx context.Context, c *GenreCatalogue) {
+	for _, collision := range c.Collisions() {
+		slog.WarnContext(ctx, "genre name collision", "detail", collision)
+	}
+}
+
+// Resolve returns the slug of the genre known by the given name, ignoring case and
+// punctuation, and whether there is one.
+func (c *GenreCatalogue) Resolve(name string) (string, bool) {
+	slug, ok := c.slugs[genreKey(name)]
+	return slug, ok
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre, catalogue *GenreCatalogue) {
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(len(genre.Slug) <= 50, "slug", "must not be more than 50 bytes long")
+	v.Check(genre.Slug == genreKey(genre.Slug), "slug", "must only contain lowercase letters, digits and single hyphens")
+
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(genre.Synonyms != nil, "synonyms", "must be provided")
+	v.Check(len(genre.Synonyms) <= 20, "synonyms", "must not contain more than 20 synonyms")
+	v.Check(validator.Unique(genre.Synonyms), "synonyms", "must not contain duplicate values")
+
+	for _, synonym := range genre.Synonyms {
+		v.Check(genreKey(synonym) != "", "synonyms", "must not contain blank values")
+		v.Check(len(synonym) <= 100, "synonyms", "must not contain values more than 100 bytes long")
+	}
+
+	// Every name must resolve to this genre alone, or movie genres would be ambiguous.
+	check := func(key, name string) {
+		if slug, ok := catalogue.Resolve(name); ok && slug != genre.Slug {
+			v.AddError(key, fmt.Sprintf("%q is already used by the %s genre", name, slug))
+		}
+	}
+
+	check("slug", genre.Slug)
+	check("name", genre.Name)
+	for _, synonym := range genre.Synonyms {
+		check("synonyms", synonym)
+	}
+}
+
+type GenreModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// genreColumns selects a genre along with the number of live movies tagged with it. The
+// GIN index on movies.genres serves the @> lookup.
+const genreColumns = `
+	g.id, g.created_at, g.slug, g.name, g.synonyms, g.version,
+	(SELECT count(*) FROM movies m WHERE m.genres @> ARRAY[g.slug] AND m.deleted_at IS NULL)`
+
+func scanGenre(row interface{ Scan(...interface{}) error }) (*Genre, error) {
+	var genre Genre
+
+	err := row.Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Slug,
+		&genre.Name,
+		pq.Array(&genre.Synonyms),
+		&genre.Version,
+		&genre.MovieCount,
+	)
+
+	return &genre, err
+}
+
+func (m GenreModel) Insert(ctx context.Context, genre *Genre) error {
+	query := `
+		INSERT INTO genres (slug, name, synonyms)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Insert)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, genre.Slug, genre.Name, pq.Array(genre.Synonyms)).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Version,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateGenre
+		default:
+			return contextError(ctx, err)
+		}
+	}
+
+	return nil
+}
+
+func (m GenreModel) Get(ctx context.Context, slug string) (*Genre, error) {
+	query := `SELECT ` + genreColumns + `
+		FROM genres g
+		WHERE g.slug = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Get)
+	defer cancel()
+
+	genre, err := scanGenre(m.DB.QueryRowContext(ctx, query, slug))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextError(ctx, err)
+		}
+	}
+
+	return genre, nil
+}
+
+// GetAll returns the whole catalogue ordered by name. It is small enough that it
+// doesn't need to be paginated.
+func (m GenreModel) GetAll(ctx context.Context) ([]*Genre, error) {
+	query := `SELECT ` + genreColumns + `
+		FROM genres g
+		ORDER BY g.name, g.id`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.List)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	genres := []*Genre{}
+	for rows.Next() {
+		genre, err := scanGenre(rows)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+
+		genres = append(genres, genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return genres, nil
+}
+
+// Update saves the name and synonyms of a genre, using its version for optimistic
+// locking in the same way as MovieModel.Update(). The slug is never changed, since
+// movies refer to it.
+func (m GenreModel) Update(ctx context.Context, genre *Genre) error {
+	query := `
+		UPDATE genres
+		SET name = $1, synonyms = $2, version = version + 1
+		WHERE slug = $3 AND version = $4
+		RETURNING version
+	`
+
+	args := []interface{}{genre.Name, pq.Array(genre.Synonyms), genre.Slug, genre.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Update)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return contextError(ctx, err)
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a genre from the catalogue. It returns ErrGenreInUse if any movie,
+// including those in the trash, is still tagged with it.
+func (m GenreModel) Delete(ctx context.Context, slug string) error {
+	query := `
+		DELETE FROM genres
+		WHERE slug = $1 AND NOT EXISTS (SELECT 1 FROM movies WHERE genres @> ARRAY[$1])
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Delete)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, slug)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 1 {
+		return nil
+	}
+
+	// Nothing was deleted, either because the genre doesn't exist or because it is in
+	// use. Find out which.
+	var exists bool
+	err = m.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM genres WHERE slug = $1)`, slug).Scan(&exists)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	if exists {
+		return ErrGenreInUse
+	}
+
+	return ErrRecordNotFound
+}
+
+// Catalogue returns a GenreCatalogue for the genres currently in the database.
+func (m GenreModel) Catalogue(ctx context.Context) (*GenreCatalogue, error) {
+	query := `SELECT slug, name, synonyms FROM genres ORDER BY slug`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.List)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	genres := []*Genre{}
+	for rows.Next() {
+		var genre Genre
+
+		if err := rows.Scan(&genre.Slug, &genre.Name, pq.Array(&genre.Synonyms)); err != nil {
+			return nil, contextError(ctx, err)
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	catalogue := NewGenreCatalogue(genres)
+	logCollisions(ctx, catalogue)
+
+	return catalogue, nil
+}
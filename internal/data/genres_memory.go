@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryGenreModel is the in-memory implementation of GenreRepository. It starts out
+// with DefaultGenres, and counts movies using the MemoryMovieModel it is attached to.
+type MemoryGenreModel struct {
+	mu     sync.RWMutex
+	nextID int64
+	genres map[string]*Genre
+	movies *MemoryMovieModel
+}
+
+func NewMemoryGenreModel(movies *MemoryMovieModel) *MemoryGenreModel {
+	m := &MemoryGenreModel{
+		nextID: 1,
+		genres: make(map[string]*Genre),
+		movies: movies,
+	}
+
+	createdAt := time.Now().Truncate(time.Second)
+	for _, genre := range DefaultGenres {
+		c := copyGenre(genre)
+		c.ID = m.nextID
+		c.CreatedAt = createdAt
+		c.Version = 1
+		if c.Synonyms == nil {
+			c.Synonyms = []string{}
+		}
+
+		m.genres[c.Slug] = c
+		m.nextID++
+	}
+
+	return m
+}
+
+func (m *MemoryGenreModel) Insert(ctx context.Context, genre *Genre) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.genres[genre.Slug]; exists {
+		return ErrDuplicateGenre
+	}
+
+	genre.ID = m.nextID
+	genre.CreatedAt = time.Now().Truncate(time.Second)
+	genre.Version = 1
+	genre.MovieCount = 0
+
+	m.nextID++
+	m.genres[genre.Slug] = copyGenre(genre)
+
+	return nil
+}
+
+func (m *MemoryGenreModel) Get(ctx context.Context, slug string) (*Genre, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	genre, ok := m.genres[slug]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	c := copyGenre(genre)
+	c.MovieCount = m.movies.countGenre(slug, false)
+
+	return c, nil
+}
+
+func (m *MemoryGenreModel) GetAll(ctx context.Context) ([]*Genre, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	genres := make([]*Genre, 0, len(m.genres))
+	for _, genre := range m.genres {
+		c := copyGenre(genre)
+		c.MovieCount = m.movies.countGenre(genre.Slug, false)
+		genres = append(genres, c)
+	}
+
+	sort.Slice(genres, func(i, j int) bool {
+		if genres[i].Name != genres[j].Name {
+			return genres[i].Name < genres[j].Name
+		}
+		return genres[i].ID < genres[j].ID
+	})
+
+	return genres, nil
+}
+
+func (m *MemoryGenreModel) Update(ctx context.Context, genre *Genre) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.genres[genre.Slug]
+	if !ok || stored.Version != genre.Version {
+		return ErrEditConflict
+	}
+
+	genre.Version++
+
+	stored.Name = genre.Name
+	stored.Synonyms = append([]string{}, genre.Synonyms...)
+	stored.Version = genre.Version
+
+	return nil
+}
+
+func (m *MemoryGenreModel) Delete(ctx context.Context, slug string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.genres[slug]; !ok {
+		return ErrRecordNotFound
+	}
+
+	if m.movies.countGenre(slug, true) > 0 {
+		return ErrGenreInUse
+	}
+
+	delete(m.genres, slug)
+
+	return nil
+}
+
+func (m *MemoryGenreModel) Catalogue(ctx context.Context) (*GenreCatalogue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	genres := make([]*Genre, 0, len(m.genres))
+	for _, genre := range m.genres {
+		genres = append(genres, genre)
+	}
+
+	// Same order as GenreModel.Catalogue(), so that both resolve collisions alike.
+	sort.Slice(genres, func(i, j int) bool { return genres[i].Slug < genres[j].Slug })
+
+	catalogue := NewGenreCatalogue(genres)
+	logCollisions(ctx, catalogue)
+
+	return catalogue, nil
+}
+
+// countGenre returns the number of movies tagged with the genre. Trashed movies are
+// only counted if includeTrashed is true.
+func (m *MemoryMovieModel) countGenre(slug string, includeTrashed bool) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, movie := range m.movies {
+		if movie.DeletedAt != nil && !includeTrashed {
+			continue
+		}
+
+		if containsAll(movie.Genres, []string{slug}) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func copyGenre(genre *Genre) *Genre {
+	c := *genre
+	if genre.Synonyms != nil {
+		c.Synonyms = append([]string{}, genre.Synonyms...)
+	}
+	return &c
+}
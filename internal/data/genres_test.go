@@ -0,0 +1,97 @@
+package data
+
+import (
+	"testing"
+
+	"greenlight.abhishek/internal/validator"
+)
+
+func TestGenreCatalogueResolve(t *testing.T) {
+	catalogue := NewGenreCatalogue(DefaultGenres)
+
+	tests := []struct {
+		name string
+		want string // Empty if the name shouldn't resolve
+	}{
+		{"sci-fi", "sci-fi"},
+		{"Sci-Fi", "sci-fi"},
+		{"SCI_FI", "sci-fi"},
+		{"Science Fiction", "sci-fi"},
+		{"sf", "sci-fi"},
+		{"Animated", "animation"},
+		{"", ""},
+		{"nope", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slug, ok := catalogue.Resolve(tt.name)
+			if slug != tt.want || ok != (tt.want != "") {
+				t.Errorf("Resolve(%q) = %q, %v; want %q", tt.name, slug, ok, tt.want)
+			}
+		})
+	}
+
+	if collisions := catalogue.Collisions(); len(collisions) != 0 {
+		t.Errorf("DefaultGenres have collisions %v", collisions)
+	}
+}
+
+func TestNewGenreCatalogueCollisions(t *testing.T) {
+	scifi := &Genre{Slug: "sci-fi", Name: "Science Fiction", Synonyms: []string{"sf"}}
+	sf := &Genre{Slug: "sf", Name: "Speculative Fiction", Synonyms: []string{"science fiction"}}
+
+	// Whatever the order, a slug resolves to its own genre, and a name shared by two
+	// genres is kept by the first one rather than overwritten.
+	for _, genres := range [][]*Genre{{scifi, sf}, {sf, scifi}} {
+		catalogue := NewGenreCatalogue(genres)
+
+		if slug, _ := catalogue.Resolve("sf"); slug != "sf" {
+			t.Errorf("Resolve(sf) = %q, want sf", slug)
+		}
+
+		if slug, _ := catalogue.Resolve("science fiction"); slug != genres[0].Slug {
+			t.Errorf("Resolve(science fiction) = %q, want %q", slug, genres[0].Slug)
+		}
+
+		if n := len(catalogue.Collisions()); n != 2 {
+			t.Errorf("got %d collisions %v, want 2", n, catalogue.Collisions())
+		}
+	}
+}
+
+func TestValidateGenre(t *testing.T) {
+	catalogue := NewGenreCatalogue(DefaultGenres)
+
+	tests := []struct {
+		name  string
+		genre Genre
+		key   string // Key of the expected error, empty if the genre is valid
+	}{
+		{"new genre", Genre{Slug: "noir", Name: "Film Noir", Synonyms: []string{}}, ""},
+		{"existing genre keeps its names", Genre{Slug: "sci-fi", Name: "Science Fiction", Synonyms: []string{"scifi", "sf"}}, ""},
+		{"slug used as a synonym", Genre{Slug: "sf", Name: "Speculative", Synonyms: []string{}}, "slug"},
+		{"name used by another genre", Genre{Slug: "noir", Name: "Drama", Synonyms: []string{}}, "name"},
+		{"synonym used by another genre", Genre{Slug: "noir", Name: "Film Noir", Synonyms: []string{"cartoon"}}, "synonyms"},
+		{"invalid slug", Genre{Slug: "Film Noir", Name: "Film Noir", Synonyms: []string{}}, "slug"},
+		{"missing synonyms", Genre{Slug: "noir", Name: "Film Noir"}, "synonyms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateGenre(v, &tt.genre, catalogue)
+
+			if tt.key == "" {
+				if !v.Valid() {
+					t.Errorf("unexpected errors %v", v.Errors)
+				}
+				return
+			}
+
+			if _, ok := v.Errors[tt.key]; !ok {
+				t.Errorf("errors = %v, want an error for %q", v.Errors, tt.key)
+			}
+		})
+	}
+}
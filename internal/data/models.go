@@ -1,8 +1,12 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 )
 
 var (
@@ -10,12 +14,200 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// Timeouts holds the maximum time each kind of database operation is allowed to run
+// for. The deadline is applied on top of the caller's context, so whichever expires
+// first wins.
+type Timeouts struct {
+	Insert time.Duration
+	Get    time.Duration
+	Update time.Duration
+	Delete time.Duration
+	List   time.Duration
+	Bulk   time.Duration
+	Export time.Duration
+}
+
+// MovieRepository is the set of operations supported by every movie storage backend.
+// Implementations must behave identically: Update() uses the movie's Version for
+// optimistic locking and returns ErrEditConflict on a mismatch, as does Delete() when
+// given a non-zero version. Get() and Delete() return ErrRecordNotFound for unknown or
+// trashed ids otherwise, and GetAll(), Export() and Facets() apply the same search,
+// sorting and pagination rules. Get() and GetAll() may leave any fields other than the
+// requested ones and the id unset.
+type MovieRepository interface {
+	Insert(ctx context.Context, movie *Movie) error
+	InsertMany(ctx context.Context, movies []*Movie) error
+	Get(ctx context.Context, id int64, fields ...string) (*Movie, error)
+	Update(ctx context.Context, movie *Movie) error
+	Delete(ctx context.Context, id int64, version int32) error
+	GetAll(ctx context.Context, search MovieSearch, filters Filters) ([]*Movie, Metadata, error)
+	Export(ctx context.Context, search MovieSearch, filters Filters, fn func(*Movie) error) error
+	Facets(ctx context.Context, search MovieSearch, names []string) (Facets, error)
+
+	// UpdatePoster() saves the movie's Poster, with the same optimistic locking as
+	// Update().
+	UpdatePoster(ctx context.Context, movie *Movie) error
+
+	// Delete() only moves a movie to the trash. These methods manage trashed movies.
+	Restore(ctx context.Context, id int64) (*Movie, error)
+	GetAllTrashed(ctx context.Context, filters Filters) ([]*Movie, Metadata, error)
+	Purge(ctx context.Context, id int64) (*Movie, error)
+}
+
+// RevisionRepository provides read access to the history of changes made through a
+// MovieRepository. Revisions are written by the MovieRepository itself.
+type RevisionRepository interface {
+	GetAll(ctx context.Context, movieID int64, filters Filters) ([]*Revision, Metadata, error)
+	Get(ctx context.Context, movieID int64, version int32) (*Revision, error)
+}
+
+// GenreRepository manages the catalogue of genres which movies can be tagged with.
+// Update() uses the genre's Version for optimistic locking, and Delete() returns
+// ErrGenreInUse while any movie is tagged with the genre.
+type GenreRepository interface {
+	Insert(ctx context.Context, genre *Genre) error
+	Get(ctx context.Context, slug string) (*Genre, error)
+	GetAll(ctx context.Context) ([]*Genre, error)
+	Update(ctx context.Context, genre *Genre) error
+	Delete(ctx context.Context, slug string) error
+	Catalogue(ctx context.Context) (*GenreCatalogue, error)
+}
+
+// PersonRepository manages the people who can be credited on movies. Update() uses
+// the person's Version for optimistic locking, and Delete() also removes the person's
+// credits.
+type PersonRepository interface {
+	Insert(ctx context.Context, person *Person) error
+	Get(ctx context.Context, id int64) (*Person, error)
+	GetAll(ctx context.Context, name string, filters Filters) ([]*Person, Metadata, error)
+	Update(ctx context.Context, person *Person) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// CreditRepository manages the cast and crew credited on each movie. The credits of a
+// movie are always replaced as a whole.
+type CreditRepository interface {
+	GetForMovie(ctx context.Context, movieID int64) ([]*Credit, error)
+	ReplaceForMovie(ctx context.Context, movieID int64, credits []*Credit) error
+}
+
+// ReviewRepository manages the reviews of movies. Every change to the reviews of a
+// movie also updates the movie's Rating and RatingCount, atomically. Update() uses the
+// review's Version for optimistic locking, and returns ErrRecordNotFound if the movie
+// has been trashed or removed.
+type ReviewRepository interface {
+	Insert(ctx context.Context, review *Review) error
+	Get(ctx context.Context, movieID, id int64) (*Review, error)
+	GetAll(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error)
+	Update(ctx context.Context, review *Review) error
+	Delete(ctx context.Context, movieID, id int64) error
+}
+
 type Models struct {
-	Movies MovieModel
+	Movies    MovieRepository
+	Revisions RevisionRepository
+	Genres    GenreRepository
+	People    PersonRepository
+	Credits   CreditRepository
+	Reviews   ReviewRepository
 }
 
-func NewModels(db *sql.DB) Models {
+func NewModels(db *sql.DB, timeouts Timeouts) Models {
 	return Models{
-		Movies: MovieModel{DB: db},
+		Movies:    MovieModel{DB: db, Timeouts: timeouts},
+		Revisions: RevisionModel{DB: db, Timeouts: timeouts},
+		Genres:    GenreModel{DB: db, Timeouts: timeouts},
+		People:    PersonModel{DB: db, Timeouts: timeouts},
+		Credits:   CreditModel{DB: db, Timeouts: timeouts},
+		Reviews:   ReviewModel{DB: db, Timeouts: timeouts},
+	}
+}
+
+// NewMemoryModels returns models backed by in-process storage instead of PostgreSQL.
+// Nothing is persisted, which makes them suitable for tests and local development.
+func NewMemoryModels() Models {
+	movies := NewMemoryMovieModel()
+
+	return Models{
+		Movies:    movies,
+		Revisions: MemoryRevisionModel{movies: movies},
+		Genres:    NewMemoryGenreModel(movies),
+		People:    MemoryPersonModel{movies: movies},
+		Credits:   MemoryCreditModel{movies: movies},
+		Reviews:   MemoryReviewModel{movies: movies},
+	}
+}
+
+// contextError() makes sure that a failure caused by the context being cancelled or
+// timing out can be detected with errors.Is(). lib/pq reports a cancelled query as a
+// "canceling statement" server error rather than returning the context's error, so the
+// context is checked directly and its error wrapped alongside the original one.
+//
+// Queries which hit their deadline are logged, through the default logger so that the
+// record carries the details of the request from ctx, since a slow query is something
+// to look into even when the caller handles the error.
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	ctxErr := ctx.Err()
+
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		slog.WarnContext(ctx, "database query timed out", "error", err.Error())
+	}
+
+	if ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %w", ctxErr, err)
 	}
+
+	return err
+}
+
+// execOne executes a statement which is expected to affect exactly one row, returning
+// ErrRecordNotFound if it affected none.
+func execOne(ctx context.Context, db *sql.DB, timeout time.Duration, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Execute the SQL query using the Exec() method. The Exec() method returns a
+	// sql.Result object.
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	// Call the RowsAffected() method on the sql.Result object to get the number of rows
+	// affected by the query.
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// If no rows were affected, we know that the table didn't contain a matching
+	// record at the moment we ran the query. In that case we return an
+	// ErrRecordNotFound error.
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// withTx() runs fn inside a database transaction, committing the transaction if fn
+// succeeds and rolling it back otherwise.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback() is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -12,20 +13,38 @@ import (
 )
 
 type Movie struct {
-	ID        int64     `json:"id"`         // Unique integer ID for the movie
-	CreatedAt time.Time `json:"created_at"` // Timestamp for when the movie is added to our database
-	Title     string    `json:"title"`      // Movie Title
-	Year      int32     `json:"year"`       // Movie release year
-	Runtime   Runtime   `json:"runtime"`    // Movie Runtime (in minutes)
-	Genres    []string  `json:"genres"`     // Slice of genres for the movie.
-	Version   int32     `json:"version"`    // The version number starts at 1 and will be incremented each time the movie information is updated.
+	ID          int64      `json:"id"`                   // Unique integer ID for the movie
+	CreatedAt   time.Time  `json:"created_at"`           // Timestamp for when the movie is added to our database
+	Title       string     `json:"title"`                // Movie Title
+	Year        int32      `json:"year"`                 // Movie release year
+	Runtime     Runtime    `json:"runtime"`              // Movie Runtime (in minutes)
+	Genres      []string   `json:"genres"`               // Slice of genres for the movie.
+	Rating      float64    `json:"rating"`               // Average review score, zero until the movie is reviewed.
+	RatingCount int32      `json:"rating_count"`         // Number of reviews the rating is based on.
+	Poster      Poster     `json:"poster,omitempty"`     // Poster images, nil until a poster is uploaded.
+	Version     int32      `json:"version"`              // The version number starts at 1 and will be incremented each time the movie information is updated.
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // Timestamp for when the movie was moved to the trash, nil for live movies.
+	Match       *Match     `json:"match,omitempty"`      // How well the movie matched a title search, nil outside of searches.
+	Credits     []*Credit  `json:"credits,omitempty"`    // Cast and crew, only filled in when requested.
+}
+
+// relevance returns how well the movie matched a title search, or zero if it wasn't
+// the result of one.
+func (m *Movie) relevance() float64 {
+	if m.Match == nil {
+		return 0
+	}
+	return m.Match.Relevance
 }
 
 type MovieModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// ValidateMovie checks the movie and normalizes its genres, replacing each of them with
+// the slug of the catalogue genre it names.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres *GenreCatalogue) {
 	// Title validation
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
@@ -43,10 +62,21 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(movie.Genres != nil, "genres", "must be provided")
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
+
+	for i, genre := range movie.Genres {
+		slug, ok := genres.Resolve(genre)
+		if !ok {
+			v.AddError("genres", fmt.Sprintf("%q is not a known genre", genre))
+			continue
+		}
+		movie.Genres[i] = slug
+	}
+
+	// Checked after normalizing, so that "Sci-Fi" and "scifi" count as duplicates.
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+func (m MovieModel) Insert(ctx context.Context, movie *Movie) error {
 	query := `
 		INSERT INTO movies (title, year, runtime, genres)
 		VALUES ($1, $2, $3, $4)
@@ -60,31 +90,132 @@ func (m MovieModel) Insert(movie *Movie) error {
 		pq.Array(movie.Genres),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Insert)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	// Insert the movie and its first revision in a single transaction.
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+		if err != nil {
+			return err
+		}
+
+		return insertRevision(ctx, tx, RevisionCreate, movie)
+	})
+
+	return contextError(ctx, err)
 }
 
-func (m MovieModel) Get(id int64) (*Movie, error) {
-	if id < 1 {
-		return nil, ErrRecordNotFound
+// insertManyBatchSize is the number of movies inserted by each multi-row INSERT
+// statement in InsertMany(). It keeps the number of placeholders per statement well
+// below PostgreSQL's limit of 65535.
+const insertManyBatchSize = 500
+
+// InsertMany inserts a batch of movies, along with their first revisions, in a single
+// transaction: either all of the movies are inserted or none of them are. The ID,
+// CreatedAt and Version fields of each movie are set on success.
+func (m MovieModel) InsertMany(ctx context.Context, movies []*Movie) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Bulk)
+	defer cancel()
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		for start := 0; start < len(movies); start += insertManyBatchSize {
+			batch := movies[start:min(start+insertManyBatchSize, len(movies))]
+
+			if err := insertMovieBatch(ctx, tx, batch); err != nil {
+				return err
+			}
+
+			if err := insertRevisionBatch(ctx, tx, RevisionCreate, batch); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return contextError(ctx, err)
+}
+
+// insertMovieBatch inserts the movies with one multi-row INSERT statement.
+//
+// PostgreSQL doesn't promise to assign the ids, or to return the inserted rows, in the
+// order of the input. So each input row is numbered, its id is drawn from the sequence
+// up front, and the numbers are returned along with the inserted rows to match them up
+// with the input. The numbered CTE is only evaluated once, since nextval() is volatile.
+func insertMovieBatch(ctx context.Context, tx *sql.Tx, movies []*Movie) error {
+	values := make([]string, 0, len(movies))
+	args := make([]interface{}, 0, len(movies)*5)
+
+	for i, movie := range movies {
+		n := i * 5
+		values = append(values, fmt.Sprintf("($%d::integer, $%d::text, $%d::integer, $%d::integer, $%d::text[])", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, i, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres))
 	}
 
-	// SQL query for retrieving the movie data
 	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
-		FROM movies
-		WHERE id = $1
-	`
+		WITH input (ordinality, title, year, runtime, genres) AS (
+			VALUES ` + strings.Join(values, ", ") + `
+		), numbered AS (
+			SELECT nextval(pg_get_serial_sequence('movies', 'id')) AS id, input.*
+			FROM input
+		), inserted AS (
+			INSERT INTO movies (id, title, year, runtime, genres)
+			SELECT id, title, year, runtime, genres FROM numbered
+			RETURNING id, created_at, version
+		)
+		SELECT numbered.ordinality, inserted.id, inserted.created_at, inserted.version
+		FROM inserted
+		INNER JOIN numbered ON numbered.id = inserted.id`
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i int
+		var inserted Movie
+
+		if err := rows.Scan(&i, &inserted.ID, &inserted.CreatedAt, &inserted.Version); err != nil {
+			return err
+		}
+
+		if i < 0 || i >= len(movies) {
+			return fmt.Errorf("insert returned unexpected ordinality %d", i)
+		}
+
+		movies[i].ID = inserted.ID
+		movies[i].CreatedAt = inserted.CreatedAt
+		movies[i].Version = inserted.Version
+	}
+
+	return rows.Err()
+}
+
+// Get returns a live movie. If any fields are given, only those columns (and the id)
+// are read, and the rest of the movie is left empty.
+func (m MovieModel) Get(ctx context.Context, id int64, fields ...string) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
 
 	// Movie struct to hold the data returned by the query.
 	var movie Movie
 
-	// Use the context.WithTimeout() function to create a context.Context which carries a
-	// 3-sec timeout deadlince. Note that we're using the empty context.Background()
-	// as the 'parent' context.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	columns, dest := movieColumns(&movie, fields)
+
+	// SQL query for retrieving the movie data
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM movies
+		WHERE id = $1 AND deleted_at IS NULL`, columns)
+
+	// Use the context.WithTimeout() function to create a context.Context which carries
+	// the configured timeout deadline. The parent is the request context, so the query
+	// is also cancelled if the client goes away.
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Get)
 
 	// Importantly, use defer to make suret that we cancel the context beforet the Get()
 	// method returns.
@@ -92,37 +223,28 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 
 	// Use the QueryRowContext() method to execute the query, passing in the context
 	// with the deadline as the first argument.
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-	)
-
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(dest...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrRecordNotFound
 		default:
-			return nil, err
+			return nil, contextError(ctx, err)
 		}
 	}
 
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+func (m MovieModel) Update(ctx context.Context, movie *Movie) error {
 	// Declare the SQL query for updating the record and returning the new version
 	// number.
 
 	query := `
 		UPDATE movies
 		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
-		RETURNING version
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING version, created_at, rating, rating_count, poster
 	`
 
 	// Create an args slice containing the values for the placeholder parameters.
@@ -135,106 +257,466 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Update)
 	defer cancel()
 
 	// Use the QueryRow() method to execute the query, passing in the args slice as a
-	// variadic parameter and scanning the new version value into the movie struct.
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	// variadic parameter and scanning the new version value into the movie struct. The
+	// revision is recorded in the same transaction.
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version, &movie.CreatedAt, &movie.Rating, &movie.RatingCount, &movie.Poster)
+		if err != nil {
+			return err
+		}
+
+		return insertRevision(ctx, tx, RevisionUpdate, movie)
+	})
+
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
+			return contextError(ctx, err)
+		}
+	}
+
+	return nil
+}
+
+// UpdatePoster replaces the poster of a live movie, using the movie's version for
+// optimistic locking like Update(). The change is recorded in the revision history.
+func (m MovieModel) UpdatePoster(ctx context.Context, movie *Movie) error {
+	query := `
+		UPDATE movies
+		SET poster = $1, version = version + 1
+		WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+		RETURNING version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Update)
+	defer cancel()
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, movie.Poster, movie.ID, movie.Version).Scan(&movie.Version)
+		if err != nil {
 			return err
 		}
+
+		return insertRevision(ctx, tx, RevisionPoster, movie)
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return contextError(ctx, err)
+		}
 	}
 
 	return nil
 }
 
-func (m MovieModel) Delete(id int64) error {
+// Delete moves a movie to the trash by setting its deleted_at timestamp. Trashed movies
+// are hidden from Get() and GetAll() but can be brought back with Restore(), or
+// removed for good with Purge(). If version is non-zero, the movie is only deleted if
+// it is still at that version, and ErrEditConflict is returned otherwise.
+func (m MovieModel) Delete(ctx context.Context, id int64, version int32) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	// Construct the SQL query to delete the record.
+	// Construct the SQL query to mark the record as deleted. Bumping the version means
+	// that any client still holding the old version will get an edit conflict.
+	query := `
+		UPDATE movies
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND ($2::integer = 0 OR version = $2) AND deleted_at IS NULL
+		RETURNING id, created_at, title, year, runtime, genres, rating, rating_count, poster, version, deleted_at
+	`
+
+	_, err := m.trash(ctx, RevisionDelete, query, id, version)
+	if errors.Is(err, ErrRecordNotFound) && version != 0 {
+		return ErrEditConflict
+	}
+
+	return err
+}
+
+// Restore takes a movie back out of the trash and returns the restored record.
+func (m MovieModel) Restore(ctx context.Context, id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, created_at, title, year, runtime, genres, rating, rating_count, poster, version, deleted_at
+	`
+
+	return m.trash(ctx, RevisionRestore, query, id)
+}
+
+// trash runs a query which moves a movie into or out of the trash and returns the
+// updated record, recording the change in the revision history.
+func (m MovieModel) trash(ctx context.Context, operation string, query string, args ...interface{}) (*Movie, error) {
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Delete)
+	defer cancel()
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Rating,
+			&movie.RatingCount,
+			&movie.Poster,
+			&movie.Version,
+			&movie.DeletedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		return insertRevision(ctx, tx, operation, &movie)
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextError(ctx, err)
+		}
+	}
+
+	return &movie, nil
+}
+
+// Purge permanently removes a movie which is in the trash, along with its revision
+// history, and returns the removed record. Live movies must be deleted before they can
+// be purged.
+func (m MovieModel) Purge(ctx context.Context, id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
 	query := `
 		DELETE FROM movies
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, created_at, title, year, runtime, genres, rating, rating_count, poster, version, deleted_at
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Delete)
 	defer cancel()
 
-	// Execute the SQL query using the Exec() method, passing in the id variable as
-	// the value fro the placeholder parameter. The Exec() method returns a sql.Result
-	// object.
-	result, err := m.DB.ExecContext(ctx, query, id)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Rating,
+		&movie.RatingCount,
+		&movie.Poster,
+		&movie.Version,
+		&movie.DeletedAt,
+	)
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextError(ctx, err)
+		}
 	}
 
-	// Call the RowsAffected() method on the sql.Result object to get the number of rows
-	// affected by the query.
-	rowsAffected, err := result.RowsAffected()
+	return &movie, nil
+}
+
+func (m MovieModel) GetAll(ctx context.Context, search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	if search.matchesNothing() {
+		return []*Movie{}, Metadata{}, nil
+	}
+
+	movies, metadata, err := m.list(ctx, search.query(), filters)
 	if err != nil {
-		return err
+		return nil, Metadata{}, err
 	}
 
-	// If no rows were affected, we know that the movies table didn't contain a record
-	// with the provided ID at the moment we tried to delete it. In that case we
-	// return an ErrRecrdNotFound error.
+	// When an exact search comes up empty, look for the closest title with a fuzzy
+	// search, so that the client can offer it as a "did you mean" suggestion.
+	if fuzzy, ok := search.suggestion(filters, movies); ok {
+		suggestions, _, err := m.list(ctx, fuzzy.query(), suggestionFilters)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+		if len(suggestions) > 0 {
+			metadata.Suggestion = suggestions[0].Title
+		}
 	}
 
-	return nil
+	return movies, metadata, nil
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, error) {
+// exportBatchSize is the number of rows fetched from the database cursor at a time by
+// Export().
+const exportBatchSize = 500
+
+// Export calls fn for every movie matching the same search criteria as GetAll(), in
+// the order given by filters.Sort. Pagination fields are ignored. The rows are read
+// through a server-side cursor in batches, so memory use stays flat no matter how many
+// movies match. If fn returns an error the export stops and that error is returned.
+func (m MovieModel) Export(ctx context.Context, search MovieSearch, filters Filters, fn func(*Movie) error) error {
+	if search.matchesNothing() {
+		return nil
+	}
+
+	q := search.query()
+
 	query := fmt.Sprintf(`
-		SELECT id, created_at, title, year, runtime, genres, version
-		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (genres @> $2 OR $2 = '{}')
-		ORDER BY %s %s, id ASC`, filters.sortColumn(), filters.sortDirection())
+		DECLARE movies_export NO SCROLL CURSOR FOR
+		SELECT id, created_at, title, year, runtime, genres, rating, rating_count, poster, version
+		FROM (
+			SELECT id, created_at, title, year, runtime, genres, rating, rating_count, poster, version, %s AS relevance
+			FROM movies
+			WHERE %s
+		) AS matches
+		ORDER BY %s %s, id ASC`, q.relevance, q.where, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Export)
+	defer cancel()
+
+	// Cursors only live as long as the transaction they are declared in. A read-only
+	// transaction is enough, since nothing is written.
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, q.args...); err != nil {
+		return contextError(ctx, err)
+	}
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM movies_export", exportBatchSize)
+
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return contextError(ctx, err)
+		}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		fetched := 0
+		for rows.Next() {
+			var movie Movie
+			err := rows.Scan(
+				&movie.ID,
+				&movie.CreatedAt,
+				&movie.Title,
+				&movie.Year,
+				&movie.Runtime,
+				pq.Array(&movie.Genres),
+				&movie.Rating,
+				&movie.RatingCount,
+				&movie.Poster,
+				&movie.Version,
+			)
+			if err != nil {
+				rows.Close()
+				return contextError(ctx, err)
+			}
+
+			fetched++
+
+			if err := fn(&movie); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return contextError(ctx, err)
+		}
+
+		// A short batch means the cursor is exhausted.
+		if fetched < exportBatchSize {
+			break
+		}
+	}
+
+	return contextError(ctx, tx.Commit())
+}
+
+// Facets counts the movies matching the search by each of the named facets. The
+// counts cover every match, not just one page of them, and are computed with a single
+// query which aggregates the matches once per facet.
+func (m MovieModel) Facets(ctx context.Context, search MovieSearch, names []string) (Facets, error) {
+	facets := newFacets(names)
+
+	if len(names) == 0 || search.matchesNothing() {
+		return facets, nil
+	}
+
+	aggregates := map[string]string{
+		"genres":   `SELECT 'genres', genre, count(*) FROM matches, unnest(genres) AS genre GROUP BY 2`,
+		"decades":  `SELECT 'decades', (year / 10 * 10) || 's', count(*) FROM matches GROUP BY 2`,
+		"runtimes": fmt.Sprintf(`SELECT 'runtimes', %s, count(*) FROM matches GROUP BY 2`, runtimeBucketSQL()),
+	}
+
+	selects := make([]string, 0, len(names))
+	for _, name := range names {
+		selects = append(selects, aggregates[name])
+	}
+
+	q := search.query()
+
+	query := fmt.Sprintf(`
+		WITH matches AS (
+			SELECT genres, year, runtime
+			FROM movies
+			WHERE %s
+		)
+		%s`, q.where, strings.Join(selects, "\n\t\tUNION ALL\n\t\t"))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.List)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, title, pq.Array(genres))
+	rows, err := m.DB.QueryContext(ctx, query, q.args...)
 	if err != nil {
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 
 	defer rows.Close()
 
+	for rows.Next() {
+		var name, value string
+		var count int
+
+		if err := rows.Scan(&name, &value, &count); err != nil {
+			return nil, contextError(ctx, err)
+		}
+
+		facets.add(name, value, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	facets.sort()
+
+	return facets, nil
+}
+
+// GetAllTrashed returns the movies which have been soft deleted and can still be
+// restored or purged.
+func (m MovieModel) GetAllTrashed(ctx context.Context, filters Filters) ([]*Movie, Metadata, error) {
+	q := movieQuery{
+		where:     "deleted_at IS NOT NULL",
+		relevance: "0::real",
+		headline:  "NULL",
+	}
+
+	return m.list(ctx, q, filters)
+}
+
+// list runs a paginated query over the movies selected by q. The pagination
+// placeholders are numbered after the ones used by q.
+func (m MovieModel) list(ctx context.Context, q movieQuery, filters Filters) ([]*Movie, Metadata, error) {
+	cursor, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	n := len(q.args)
+	args := append(q.args, filters.limit(), filters.offset())
+
+	// In keyset mode, seek past the cursor position instead of using OFFSET. The id
+	// comparison breaks ties between rows that share the same sort value, mirroring the
+	// "id ASC" tie-breaker in the ORDER BY clause.
+	seek := "TRUE"
+	if cursor != nil {
+		seek = fmt.Sprintf("(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id > $%[4]d))",
+			filters.sortColumn(), filters.seekOperator(), n+3, n+4)
+		args = append(args, cursor.Value, cursor.ID)
+	}
+
+	// The innermost query computes the relevance of every matching row, so that it can
+	// be sorted and seeked on like a regular column. The count(*) OVER() window
+	// function then returns the total number of matching rows, before LIMIT and OFFSET
+	// are applied. The headline is only worked out in the outer query, for the rows on
+	// the requested page, since ts_headline() is relatively expensive. Only the outer
+	// query is limited to the requested fields.
+	columns, _ := movieColumns(&Movie{}, filters.fields())
+
+	query := fmt.Sprintf(`
+		SELECT total, %[9]s, deleted_at, relevance, %[1]s
+		FROM (
+			SELECT count(*) OVER() AS total, *
+			FROM (
+				SELECT id, created_at, title, year, runtime, genres, rating, rating_count, poster, version, deleted_at, %[2]s AS relevance
+				FROM movies
+				WHERE %[3]s
+			) AS matches
+			WHERE %[4]s
+			ORDER BY %[5]s %[6]s, id ASC
+			LIMIT $%[7]d OFFSET $%[8]d
+		) AS page
+		ORDER BY %[5]s %[6]s, id ASC`,
+		q.headline, q.relevance, q.where, seek, filters.sortColumn(), filters.sortDirection(), n+1, n+2, columns)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.List)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
 	movies := []*Movie{}
 	for rows.Next() {
 		var movie Movie
-		err := rows.Scan(
-			&movie.ID,
-			&movie.CreatedAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
-		)
+		var relevance float64
+		var headline sql.NullString
 
+		_, dest := movieColumns(&movie, filters.fields())
+		dest = append([]interface{}{&totalRecords}, dest...)
+
+		err := rows.Scan(append(dest, &movie.DeletedAt, &relevance, &headline)...)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, contextError(ctx, err)
+		}
+
+		// Only title searches produce a headline, and only they have a relevance worth
+		// reporting.
+		if headline.Valid {
+			movie.Match = &Match{Relevance: relevance, Headline: headline.String}
 		}
 
 		movies = append(movies, &movie)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, contextError(ctx, err)
 	}
 
-	return movies, nil
+	return movies, filters.metadata(movies, totalRecords), nil
 }
 
 // // Implement a MarshalJSON() method on the Movie struct, so that it satisfies the
@@ -0,0 +1,787 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryMovieModel is a thread-safe, in-memory implementation of MovieRepository. It
+// mirrors the behaviour of MovieModel so that handlers can be exercised without a
+// PostgreSQL database.
+//
+// The people, movie credits and reviews are kept here too, rather than in the models
+// which serve them, since movie searches and ratings depend on them.
+type MemoryMovieModel struct {
+	mu           sync.RWMutex
+	nextID       int64
+	movies       map[int64]*Movie
+	revisions    map[int64][]*Revision
+	nextPersonID int64
+	people       map[int64]*Person
+	credits      map[int64][]*Credit
+	nextReviewID int64
+	reviews      map[int64][]*Review
+}
+
+func NewMemoryMovieModel() *MemoryMovieModel {
+	return &MemoryMovieModel{
+		nextID:       1,
+		movies:       make(map[int64]*Movie),
+		revisions:    make(map[int64][]*Revision),
+		nextPersonID: 1,
+		people:       make(map[int64]*Person),
+		credits:      make(map[int64][]*Credit),
+		nextReviewID: 1,
+		reviews:      make(map[int64][]*Review),
+	}
+}
+
+func (m *MemoryMovieModel) Insert(ctx context.Context, movie *Movie) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	movie.ID = m.nextID
+	// The movies table stores created_at with a precision of one second.
+	movie.CreatedAt = time.Now().Truncate(time.Second)
+	movie.Version = 1
+
+	m.nextID++
+	m.movies[movie.ID] = copyMovie(movie)
+	m.recordRevision(ctx, RevisionCreate, movie)
+
+	return nil
+}
+
+func (m *MemoryMovieModel) InsertMany(ctx context.Context, movies []*Movie) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	createdAt := time.Now().Truncate(time.Second)
+
+	for _, movie := range movies {
+		movie.ID = m.nextID
+		movie.CreatedAt = createdAt
+		movie.Version = 1
+
+		m.nextID++
+		m.movies[movie.ID] = copyMovie(movie)
+		m.recordRevision(ctx, RevisionCreate, movie)
+	}
+
+	return nil
+}
+
+// Get returns the whole movie whichever fields are asked for, since there are no
+// columns to save reading.
+func (m *MemoryMovieModel) Get(ctx context.Context, id int64, fields ...string) (*Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	movie, ok := m.movies[id]
+	if !ok || movie.DeletedAt != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyMovie(movie), nil
+}
+
+func (m *MemoryMovieModel) Update(ctx context.Context, movie *Movie) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the SQL version, a missing record and a stale version are indistinguishable
+	// and both reported as an edit conflict.
+	existing, ok := m.movies[movie.ID]
+	if !ok || existing.DeletedAt != nil || existing.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	// The rating is maintained by MemoryReviewModel and the poster by UpdatePoster(),
+	// so neither is taken from the caller.
+	updated := copyMovie(movie)
+	updated.CreatedAt = existing.CreatedAt
+	updated.Rating = existing.Rating
+	updated.RatingCount = existing.RatingCount
+	updated.Poster = copyPoster(existing.Poster)
+	updated.Version++
+
+	m.movies[movie.ID] = updated
+	movie.Version = updated.Version
+	movie.CreatedAt = updated.CreatedAt
+	movie.Rating = updated.Rating
+	movie.RatingCount = updated.RatingCount
+	movie.Poster = copyPoster(updated.Poster)
+	m.recordRevision(ctx, RevisionUpdate, updated)
+
+	return nil
+}
+
+func (m *MemoryMovieModel) Delete(ctx context.Context, id int64, version int32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	movie, ok := m.movies[id]
+	if !ok || movie.DeletedAt != nil || (version != 0 && movie.Version != version) {
+		if version != 0 {
+			return ErrEditConflict
+		}
+		return ErrRecordNotFound
+	}
+
+	deletedAt := time.Now().Truncate(time.Second)
+	movie.DeletedAt = &deletedAt
+	movie.Version++
+	m.recordRevision(ctx, RevisionDelete, movie)
+
+	return nil
+}
+
+func (m *MemoryMovieModel) Restore(ctx context.Context, id int64) (*Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	movie, ok := m.movies[id]
+	if !ok || movie.DeletedAt == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	movie.DeletedAt = nil
+	movie.Version++
+	m.recordRevision(ctx, RevisionRestore, movie)
+
+	return copyMovie(movie), nil
+}
+
+func (m *MemoryMovieModel) UpdatePoster(ctx context.Context, movie *Movie) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.movies[movie.ID]
+	if !ok || existing.DeletedAt != nil || existing.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	existing.Poster = copyPoster(movie.Poster)
+	existing.Version++
+
+	movie.Version = existing.Version
+	m.recordRevision(ctx, RevisionPoster, existing)
+
+	return nil
+}
+
+func (m *MemoryMovieModel) Purge(ctx context.Context, id int64) (*Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	movie, ok := m.movies[id]
+	if !ok || movie.DeletedAt == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	delete(m.movies, id)
+	delete(m.revisions, id)
+	delete(m.credits, id)
+	delete(m.reviews, id)
+
+	return copyMovie(movie), nil
+}
+
+func (m *MemoryMovieModel) GetAll(ctx context.Context, search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	movies, metadata, err := m.list(ctx, filters, m.searchMatch(search))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if fuzzy, ok := search.suggestion(filters, movies); ok {
+		suggestions, _, err := m.list(ctx, suggestionFilters, m.searchMatch(fuzzy))
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if len(suggestions) > 0 {
+			metadata.Suggestion = suggestions[0].Title
+		}
+	}
+
+	return movies, metadata, nil
+}
+
+// searchMatch returns a function which selects the live movies matching the search
+// criteria, like MovieSearch.query() does in SQL. The function returns a copy of the
+// movie with its Match details filled in, or nil if the movie doesn't match. The
+// function must be called with the lock held.
+func (m *MemoryMovieModel) searchMatch(search MovieSearch) func(*Movie) *Movie {
+	terms := search.terms()
+	trigrams := trigramSet(search.fuzzyText())
+
+	return func(movie *Movie) *Movie {
+		if movie.DeletedAt != nil || !search.filter(movie) || !m.creditsMatch(movie.ID, search) {
+			return nil
+		}
+
+		if search.Title == "" {
+			return copyMovie(movie)
+		}
+
+		var match *Match
+		if search.fuzzy() {
+			match = matchSimilar(movie.Title, trigrams)
+		} else {
+			match = matchTitle(movie.Title, terms)
+		}
+		if match == nil {
+			return nil
+		}
+
+		c := copyMovie(movie)
+		c.Match = match
+		return c
+	}
+}
+
+func (m *MemoryMovieModel) Export(ctx context.Context, search MovieSearch, filters Filters, fn func(*Movie) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Take a sorted snapshot of the matching movies, so that fn is called without
+	// holding the lock.
+	m.mu.RLock()
+
+	column := filters.sortColumn()
+	match := m.searchMatch(search)
+
+	matches := []*Movie{}
+	for _, movie := range m.movies {
+		if c := match(movie); c != nil {
+			matches = append(matches, c)
+		}
+	}
+
+	m.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return filters.less(column, matches[i], matches[j])
+	})
+
+	for _, movie := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// The SQL export doesn't report match details either.
+		movie.Match = nil
+
+		if err := fn(movie); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryMovieModel) Facets(ctx context.Context, search MovieSearch, names []string) (Facets, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	facets := newFacets(names)
+	match := m.searchMatch(search)
+
+	counts := make(map[string]map[string]int)
+	for _, name := range names {
+		counts[name] = make(map[string]int)
+	}
+
+	m.mu.RLock()
+
+	for _, movie := range m.movies {
+		if match(movie) == nil {
+			continue
+		}
+
+		for _, name := range names {
+			switch name {
+			case "genres":
+				for _, genre := range movie.Genres {
+					counts[name][genre]++
+				}
+			case "decades":
+				counts[name][decade(movie.Year)]++
+			case "runtimes":
+				counts[name][runtimeBucketLabel(movie.Runtime)]++
+			}
+		}
+	}
+
+	m.mu.RUnlock()
+
+	for name, values := range counts {
+		for value, count := range values {
+			facets.add(name, value, count)
+		}
+	}
+
+	facets.sort()
+
+	return facets, nil
+}
+
+func (m *MemoryMovieModel) GetAllTrashed(ctx context.Context, filters Filters) ([]*Movie, Metadata, error) {
+	return m.list(ctx, filters, func(movie *Movie) *Movie {
+		if movie.DeletedAt == nil {
+			return nil
+		}
+		return copyMovie(movie)
+	})
+}
+
+// list returns a page of the movies selected by the match function, sorted and
+// paginated in the same way as MovieModel.list(). The match function returns the copy
+// of a movie to include in the results, or nil to leave it out.
+func (m *MemoryMovieModel) list(ctx context.Context, filters Filters, match func(*Movie) *Movie) ([]*Movie, Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	cursor, err := filters.cursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	column := filters.sortColumn()
+
+	var pivot *Movie
+	if cursor != nil {
+		pivot, err = cursorMovie(column, cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	matches := []*Movie{}
+	for _, movie := range m.movies {
+		c := match(movie)
+		if c == nil {
+			continue
+		}
+
+		if pivot != nil && !filters.after(column, c, pivot) {
+			continue
+		}
+
+		matches = append(matches, c)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return filters.less(column, matches[i], matches[j])
+	})
+
+	totalRecords := len(matches)
+
+	start := min(filters.offset(), len(matches))
+	end := min(start+filters.limit(), len(matches))
+
+	// The matches are already copies, so they can be handed out directly.
+	movies := matches[start:end:end]
+
+	return movies, filters.metadata(movies, totalRecords), nil
+}
+
+// recordRevision appends a snapshot of the movie to its history. The caller must hold
+// the write lock.
+func (m *MemoryMovieModel) recordRevision(ctx context.Context, operation string, movie *Movie) {
+	m.revisions[movie.ID] = append(m.revisions[movie.ID], &Revision{
+		MovieID:   movie.ID,
+		Version:   movie.Version,
+		Operation: operation,
+		Movie:     copyMovie(movie),
+		RequestID: RequestIDFromContext(ctx),
+		CreatedAt: time.Now().Truncate(time.Second),
+	})
+}
+
+// less reports whether movie a sorts before movie b, following the same
+// "ORDER BY <column> <direction>, id ASC" ordering used by the SQL queries.
+func (f Filters) less(column string, a, b *Movie) bool {
+	cmp := compareColumn(column, a, b)
+	if cmp == 0 {
+		return a.ID < b.ID
+	}
+
+	if f.sortDirection() == "DESC" {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// after reports whether a movie comes after the cursor position in the sort order.
+func (f Filters) after(column string, movie, pivot *Movie) bool {
+	cmp := compareColumn(column, movie, pivot)
+	if cmp == 0 {
+		return movie.ID > pivot.ID
+	}
+
+	if f.sortDirection() == "DESC" {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// compareColumn compares the value of a sort column for two movies, returning -1, 0 or
+// +1. Titles are compared bytewise, which can differ from the database collation for
+// non-ASCII text.
+func compareColumn(column string, a, b *Movie) int {
+	switch column {
+	case "title":
+		return strings.Compare(a.Title, b.Title)
+	case "year":
+		return compareInts(int64(a.Year), int64(b.Year))
+	case "runtime":
+		return compareInts(int64(a.Runtime), int64(b.Runtime))
+	case "relevance":
+		return compareFloats(a.relevance(), b.relevance())
+	case "rating":
+		return compareFloats(a.Rating, b.Rating)
+	default:
+		return compareInts(a.ID, b.ID)
+	}
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// cursorMovie converts a cursor into a movie holding the cursor's sort value and id, so
+// that it can be compared against stored movies with compareColumn.
+func cursorMovie(column string, cursor *Cursor) (*Movie, error) {
+	movie := &Movie{ID: cursor.ID}
+
+	switch column {
+	case "title":
+		movie.Title = cursor.Value
+		return movie, nil
+	case "relevance":
+		relevance, err := strconv.ParseFloat(cursor.Value, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		movie.Match = &Match{Relevance: relevance}
+		return movie, nil
+	case "rating":
+		rating, err := strconv.ParseFloat(cursor.Value, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		movie.Rating = rating
+		return movie, nil
+	}
+
+	value, err := strconv.ParseInt(cursor.Value, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	switch column {
+	case "year":
+		movie.Year = int32(value)
+	case "runtime":
+		movie.Runtime = Runtime(value)
+	default:
+		movie.ID = value
+	}
+
+	return movie, nil
+}
+
+// matchTitle emulates matching a title against a to_tsquery() built by
+// MovieSearch.tsQuery(): every term must match a word of the title, either exactly or,
+// for prefix terms, as a prefix. Stemming and stop words are not emulated, so every
+// language behaves like "simple". The relevance is the fraction of the title's words
+// which matched. It returns nil if the title doesn't match.
+func matchTitle(title string, terms []searchTerm) *Match {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	words := tokenize(title)
+	matchedTerms := make([]bool, len(terms))
+	matchedWords := 0
+
+	for _, word := range words {
+		hit := false
+		for i, term := range terms {
+			if term.matches(word) {
+				matchedTerms[i] = true
+				hit = true
+			}
+		}
+		if hit {
+			matchedWords++
+		}
+	}
+
+	for _, matched := range matchedTerms {
+		if !matched {
+			return nil
+		}
+	}
+
+	return &Match{
+		Relevance: float64(matchedWords) / float64(len(words)),
+		Headline:  highlight(title, terms),
+	}
+}
+
+// matchSimilar emulates a fuzzy search with pg_trgm: the relevance is the similarity()
+// of the title to the search, and the title only matches if it reaches FuzzyThreshold.
+func matchSimilar(title string, trigrams map[string]bool) *Match {
+	titleTrigrams := trigramSet(title)
+
+	shared := 0
+	for trigram := range trigrams {
+		if titleTrigrams[trigram] {
+			shared++
+		}
+	}
+
+	union := len(trigrams) + len(titleTrigrams) - shared
+	if union == 0 {
+		return nil
+	}
+
+	similarity := float64(shared) / float64(union)
+	if similarity < FuzzyThreshold {
+		return nil
+	}
+
+	return &Match{Relevance: similarity}
+}
+
+// trigramSet returns the set of trigrams of a string in the same way as pg_trgm: each
+// lower-cased word is padded with two spaces in front and one behind, and every run of
+// three characters is a trigram.
+func trigramSet(s string) map[string]bool {
+	trigrams := make(map[string]bool)
+
+	for _, word := range tokenize(s) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams[string(runes[i:i+3])] = true
+		}
+	}
+
+	return trigrams
+}
+
+func (t searchTerm) matches(word string) bool {
+	if t.prefix {
+		return strings.HasPrefix(word, t.word)
+	}
+	return word == t.word
+}
+
+// highlight wraps the words of the title which match any of the terms in <b></b> tags,
+// like ts_headline() does.
+func highlight(title string, terms []searchTerm) string {
+	var b strings.Builder
+
+	runes := []rune(title)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+
+		word := string(runes[i:j])
+		lower := strings.ToLower(word)
+
+		hit := false
+		for _, term := range terms {
+			if term.matches(lower) {
+				hit = true
+				break
+			}
+		}
+
+		if hit {
+			b.WriteString("<b>" + word + "</b>")
+		} else {
+			b.WriteString(word)
+		}
+
+		i = j
+	}
+
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenize splits a string into lower-cased words made of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// filter reports whether the movie passes the genre, year and runtime filters of the
+// search, like the SQL in MovieSearch.filterClause().
+func (s MovieSearch) filter(movie *Movie) bool {
+	if s.anyGenre() && len(s.Genres) > 0 && !containsAny(movie.Genres, s.Genres) {
+		return false
+	}
+
+	if !s.anyGenre() && !containsAll(movie.Genres, s.Genres) {
+		return false
+	}
+
+	year, runtime := int(movie.Year), int(movie.Runtime)
+
+	return (s.YearMin == 0 || year >= s.YearMin) &&
+		(s.YearMax == 0 || year <= s.YearMax) &&
+		(s.RuntimeMin == 0 || runtime >= s.RuntimeMin) &&
+		(s.RuntimeMax == 0 || runtime <= s.RuntimeMax)
+}
+
+// creditsMatch reports whether the movie credits the people named by the search, like
+// the SQL in creditClause(). The caller must hold the lock.
+func (m *MemoryMovieModel) creditsMatch(movieID int64, search MovieSearch) bool {
+	for role, name := range search.people() {
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, credit := range m.credits[movieID] {
+			person := m.people[credit.PersonID]
+			if credit.Role == role && person != nil && strings.EqualFold(person.Name, name) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containsAny reports whether at least one value in values is present in set, like the
+// PostgreSQL && array operator.
+func containsAny(set, values []string) bool {
+	for _, value := range values {
+		for _, item := range set {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsAll reports whether every value in subset is present in set, like the
+// PostgreSQL @> array operator.
+func containsAll(set, subset []string) bool {
+	for _, value := range subset {
+		found := false
+		for _, item := range set {
+			if item == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// copyMovie returns a deep copy of a movie, so that callers never share memory with
+// the stored records.
+func copyMovie(movie *Movie) *Movie {
+	c := *movie
+	if movie.Genres != nil {
+		c.Genres = append([]string(nil), movie.Genres...)
+	}
+	if movie.DeletedAt != nil {
+		deletedAt := *movie.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	if movie.Match != nil {
+		match := *movie.Match
+		c.Match = &match
+	}
+	c.Poster = copyPoster(movie.Poster)
+	return &c
+}
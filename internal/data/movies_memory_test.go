@@ -0,0 +1,162 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func insertTestMovie(t *testing.T, models Models, title string, genres ...string) *Movie {
+	t.Helper()
+
+	movie := &Movie{Title: title, Year: 1979, Runtime: 117, Genres: genres}
+	if err := models.Movies.Insert(context.Background(), movie); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	return movie
+}
+
+func TestMemoryMovieUpdate(t *testing.T) {
+	ctx := context.Background()
+	models := NewMemoryModels()
+	movie := insertTestMovie(t, models, "Alien", "sci-fi")
+
+	stale := *movie
+
+	movie.Title = "Aliens"
+	if err := models.Movies.Update(ctx, movie); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if movie.Version != 2 {
+		t.Errorf("Update() left the version at %d, want 2", movie.Version)
+	}
+
+	stale.Title = "Alien 3"
+	if err := models.Movies.Update(ctx, &stale); !errors.Is(err, ErrEditConflict) {
+		t.Errorf("Update() with a stale version error = %v, want ErrEditConflict", err)
+	}
+
+	// The stored movie is a copy, so changing the caller's struct afterwards doesn't
+	// change it.
+	movie.Title = "Prometheus"
+
+	got, err := models.Movies.Get(ctx, movie.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Title != "Aliens" || got.Version != 2 {
+		t.Errorf("Get() = %q at version %d, want \"Aliens\" at version 2", got.Title, got.Version)
+	}
+}
+
+func TestMemoryMovieTrash(t *testing.T) {
+	ctx := context.Background()
+	models := NewMemoryModels()
+	movie := insertTestMovie(t, models, "Alien", "sci-fi")
+
+	if _, err := models.Movies.Purge(ctx, movie.ID); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Purge() of a live movie error = %v, want ErrRecordNotFound", err)
+	}
+
+	if err := models.Movies.Delete(ctx, movie.ID, 0); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := models.Movies.Get(ctx, movie.ID); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Get() of a trashed movie error = %v, want ErrRecordNotFound", err)
+	}
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}
+
+	trashed, _, err := models.Movies.GetAllTrashed(ctx, filters)
+	if err != nil || len(trashed) != 1 || trashed[0].DeletedAt == nil {
+		t.Fatalf("GetAllTrashed() = %v, %v; want the trashed movie", trashed, err)
+	}
+
+	restored, err := models.Movies.Restore(ctx, movie.ID)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != movie.Version+2 {
+		t.Errorf("Restore() = %+v, want a live movie two versions on", restored)
+	}
+
+	if err := models.Movies.Delete(ctx, movie.ID, 0); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	purged, err := models.Movies.Purge(ctx, movie.ID)
+	if err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+	if purged.ID != movie.ID || purged.Title != "Alien" {
+		t.Errorf("Purge() = %+v, want the purged movie", purged)
+	}
+	if _, err := models.Movies.Restore(ctx, movie.ID); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Restore() of a purged movie error = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestMemoryMovieDelete(t *testing.T) {
+	ctx := context.Background()
+	models := NewMemoryModels()
+	movie := insertTestMovie(t, models, "Alien", "sci-fi")
+
+	if err := models.Movies.Delete(ctx, movie.ID, movie.Version+1); !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("Delete() with a stale version error = %v, want ErrEditConflict", err)
+	}
+
+	if err := models.Movies.Delete(ctx, movie.ID, movie.Version); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := models.Movies.Delete(ctx, movie.ID, 0); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Delete() of a trashed movie error = %v, want ErrRecordNotFound", err)
+	}
+
+	if err := models.Movies.Delete(ctx, movie.ID, movie.Version+1); !errors.Is(err, ErrEditConflict) {
+		t.Errorf("Delete() of a trashed movie with a version error = %v, want ErrEditConflict", err)
+	}
+}
+
+func TestMemoryMovieGetAllGenres(t *testing.T) {
+	ctx := context.Background()
+	models := NewMemoryModels()
+	insertTestMovie(t, models, "Alien", "sci-fi", "horror")
+	insertTestMovie(t, models, "Heat", "crime", "drama")
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}
+
+	tests := []struct {
+		name   string
+		genres []string
+		mode   string
+		want   []string
+	}{
+		{"all", []string{"sci-fi", "horror"}, "all", []string{"Alien"}},
+		{"all missing one", []string{"sci-fi", "drama"}, "all", nil},
+		{"any", []string{"sci-fi", "drama"}, "any", []string{"Alien", "Heat"}},
+		{"none", nil, "all", []string{"Alien", "Heat"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := MovieSearch{Language: "simple", Mode: "exact", Genres: tt.genres, GenresMode: tt.mode}
+
+			movies, _, err := models.Movies.GetAll(ctx, search, filters)
+			if err != nil {
+				t.Fatalf("GetAll() error = %v", err)
+			}
+
+			var titles []string
+			for _, movie := range movies {
+				titles = append(titles, movie.Title)
+			}
+
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("GetAll() = %v, want %v", titles, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight.abhishek/internal/validator"
+)
+
+// Person is somebody who worked on one or more movies, in front of or behind the
+// camera.
+type Person struct {
+	ID        int64     `json:"id"`                   // Unique integer ID for the person
+	CreatedAt time.Time `json:"created_at"`           // Timestamp for when the person was added
+	Name      string    `json:"name"`                 // Full name, as credited
+	BirthYear int32     `json:"birth_year,omitempty"` // Year of birth, zero if unknown
+	Version   int32     `json:"version"`              // Incremented each time the person is updated
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	if person.BirthYear != 0 {
+		v.Check(person.BirthYear >= 1800, "birth_year", "must be greater than 1800")
+		v.Check(person.BirthYear <= int32(time.Now().Year()), "birth_year", "must not be in the future")
+	}
+}
+
+type PersonModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+func (m PersonModel) Insert(ctx context.Context, person *Person) error {
+	query := `
+		INSERT INTO people (name, birth_year)
+		VALUES ($1, NULLIF($2, 0))
+		RETURNING id, created_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Insert)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, person.Name, person.BirthYear).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Version,
+	)
+
+	return contextError(ctx, err)
+}
+
+func (m PersonModel) Get(ctx context.Context, id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, COALESCE(birth_year, 0), version
+		FROM people
+		WHERE id = $1
+	`
+
+	var person Person
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Get)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Name,
+		&person.BirthYear,
+		&person.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextError(ctx, err)
+		}
+	}
+
+	return &person, nil
+}
+
+// GetAll returns a page of the people whose name contains all of the words in name,
+// or of everybody if name is empty.
+func (m PersonModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Person, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, COALESCE(birth_year, 0), version
+		FROM people
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.List)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	people := []*Person{}
+	for rows.Next() {
+		var person Person
+
+		err := rows.Scan(
+			&totalRecords,
+			&person.ID,
+			&person.CreatedAt,
+			&person.Name,
+			&person.BirthYear,
+			&person.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, contextError(ctx, err)
+		}
+
+		people = append(people, &person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+
+	return people, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Update saves the person, using its version for optimistic locking in the same way
+// as MovieModel.Update().
+func (m PersonModel) Update(ctx context.Context, person *Person) error {
+	query := `
+		UPDATE people
+		SET name = $1, birth_year = NULLIF($2, 0), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version
+	`
+
+	args := []interface{}{person.Name, person.BirthYear, person.ID, person.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Update)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return contextError(ctx, err)
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a person, along with all of their movie credits.
+func (m PersonModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM people
+		WHERE id = $1
+	`
+
+	return execOne(ctx, m.DB, m.Timeouts.Delete, query, id)
+}
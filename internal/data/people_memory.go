@@ -0,0 +1,141 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MemoryPersonModel is the in-memory implementation of PersonRepository. The people
+// are stored in the MemoryMovieModel it is attached to.
+type MemoryPersonModel struct {
+	movies *MemoryMovieModel
+}
+
+func (m MemoryPersonModel) Insert(ctx context.Context, person *Person) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	person.ID = m.movies.nextPersonID
+	person.CreatedAt = time.Now().Truncate(time.Second)
+	person.Version = 1
+
+	m.movies.nextPersonID++
+	c := *person
+	m.movies.people[person.ID] = &c
+
+	return nil
+}
+
+func (m MemoryPersonModel) Get(ctx context.Context, id int64) (*Person, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	person, ok := m.movies.people[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	c := *person
+	return &c, nil
+}
+
+func (m MemoryPersonModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Person, Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	// Like plainto_tsquery(), every word of the name must appear in the person's name.
+	words := tokenize(name)
+
+	matches := []*Person{}
+	for _, person := range m.movies.people {
+		if containsAll(tokenize(person.Name), words) {
+			c := *person
+			matches = append(matches, &c)
+		}
+	}
+
+	column := filters.sortColumn()
+	desc := filters.sortDirection() == "DESC"
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+
+		cmp := compareInts(a.ID, b.ID)
+		if column == "name" {
+			cmp = strings.Compare(a.Name, b.Name)
+		}
+
+		if cmp != 0 {
+			return (cmp < 0) != desc
+		}
+		return a.ID < b.ID
+	})
+
+	start := min(filters.offset(), len(matches))
+	end := min(start+filters.limit(), len(matches))
+
+	return matches[start:end:end], calculateMetadata(len(matches), filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryPersonModel) Update(ctx context.Context, person *Person) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	existing, ok := m.movies.people[person.ID]
+	if !ok || existing.Version != person.Version {
+		return ErrEditConflict
+	}
+
+	person.Version++
+	c := *person
+	c.CreatedAt = existing.CreatedAt
+	m.movies.people[person.ID] = &c
+
+	return nil
+}
+
+func (m MemoryPersonModel) Delete(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	if _, ok := m.movies.people[id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.movies.people, id)
+
+	// Remove the person's credits, like ON DELETE CASCADE.
+	for movieID, credits := range m.movies.credits {
+		kept := credits[:0]
+		for _, credit := range credits {
+			if credit.PersonID != id {
+				kept = append(kept, credit)
+			}
+		}
+		m.movies.credits[movieID] = kept
+	}
+
+	return nil
+}
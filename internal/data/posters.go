@@ -0,0 +1,141 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"greenlight.abhishek/internal/validator"
+)
+
+// PosterOriginal is the name of the poster image as it was uploaded.
+const PosterOriginal = "original"
+
+// PosterSize is a thumbnail size which is generated for every uploaded poster.
+type PosterSize struct {
+	Name  string
+	Width int
+}
+
+// PosterSizes lists the thumbnails generated for every poster, smallest first. The
+// height of each thumbnail follows from the aspect ratio of the original.
+var PosterSizes = []PosterSize{
+	{Name: "small", Width: 185},
+	{Name: "medium", Width: 500},
+}
+
+// PosterContentTypes lists the image formats which posters can be uploaded in.
+var PosterContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// Limits on the dimensions of uploaded posters, in pixels. The maximum keeps the memory
+// needed to decode a poster at a reasonable level.
+const (
+	minPosterDimension = 100
+	maxPosterDimension = 4000
+)
+
+// PosterImage is one stored size of a movie's poster.
+type PosterImage struct {
+	Key    string `json:"-"`      // Key of the image in blob storage
+	URL    string `json:"url"`    // Where the image can be downloaded from
+	Width  int    `json:"width"`  // Width in pixels
+	Height int    `json:"height"` // Height in pixels
+}
+
+// Poster holds the stored images of a movie's poster, keyed by PosterOriginal and the
+// names of the PosterSizes. A nil Poster means the movie doesn't have one.
+type Poster map[string]PosterImage
+
+// posterImageRecord is the form each PosterImage is stored in the movies table, which
+// unlike the JSON sent to clients includes the blob key.
+type posterImageRecord struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Value implements the driver.Valuer interface, storing the poster as JSON.
+func (p Poster) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	records := make(map[string]posterImageRecord, len(p))
+	for name, image := range p {
+		records[name] = posterImageRecord(image)
+	}
+
+	// lib/pq would send a []byte as bytea, so the JSON is passed as a string.
+	b, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface, reading a poster stored by Value().
+func (p *Poster) Scan(src interface{}) error {
+	var b []byte
+
+	switch src := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into a poster", src)
+	}
+
+	var records map[string]posterImageRecord
+	if err := json.Unmarshal(b, &records); err != nil {
+		return err
+	}
+
+	*p = make(Poster, len(records))
+	for name, record := range records {
+		(*p)[name] = PosterImage(record)
+	}
+
+	return nil
+}
+
+// Keys returns the blob keys of all of the poster's images.
+func (p Poster) Keys() []string {
+	keys := make([]string, 0, len(p))
+	for _, image := range p {
+		keys = append(keys, image.Key)
+	}
+	return keys
+}
+
+// ValidatePosterImage checks the content type and dimensions of an uploaded poster,
+// which are read from the image before it is decoded in full.
+func ValidatePosterImage(v *validator.Validator, contentType string, width, height int) {
+	v.Check(validator.In(contentType, PosterContentTypes...), "poster", "must be a JPEG, PNG or GIF image")
+
+	if !v.Valid() {
+		return
+	}
+
+	v.Check(width >= minPosterDimension && height >= minPosterDimension, "poster",
+		fmt.Sprintf("must be at least %d pixels wide and high", minPosterDimension))
+	v.Check(width <= maxPosterDimension && height <= maxPosterDimension, "poster",
+		fmt.Sprintf("must not be more than %d pixels wide or high", maxPosterDimension))
+}
+
+func copyPoster(poster Poster) Poster {
+	if poster == nil {
+		return nil
+	}
+
+	c := make(Poster, len(poster))
+	for name, image := range poster {
+		c[name] = image
+	}
+	return c
+}
@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight.abhishek/internal/validator"
+)
+
+// Review is an audience member's opinion of a movie. The scores of all the reviews of
+// a movie are aggregated into its Rating and RatingCount.
+type Review struct {
+	ID        int64     `json:"id"`         // Unique integer ID for the review
+	CreatedAt time.Time `json:"created_at"` // Timestamp for when the review was posted
+	MovieID   int64     `json:"movie_id"`   // ID of the reviewed movie
+	Score     int32     `json:"score"`      // Score out of 10
+	Body      string    `json:"body"`       // Text of the review
+	Version   int32     `json:"version"`    // Incremented each time the review is updated
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Score != 0, "score", "must be provided")
+	v.Check(review.Score >= 1 && review.Score <= 10, "score", "must be between 1 and 10")
+
+	v.Check(review.Body != "", "body", "must be provided")
+	v.Check(len(review.Body) <= 10_000, "body", "must not be more than 10000 bytes long")
+}
+
+type ReviewModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// lockMovie locks the row of a live movie for the rest of the transaction, returning
+// ErrRecordNotFound if there isn't one. Reviews of the same movie are written one at
+// a time as a result, so that the aggregated rating can't miss a concurrent change.
+func lockMovie(ctx context.Context, tx *sql.Tx, movieID int64) error {
+	var id int64
+
+	err := tx.QueryRowContext(ctx, `SELECT id FROM movies WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, movieID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+
+	return err
+}
+
+// updateRating recomputes the aggregated rating of a movie from its reviews. It must
+// be called in the same transaction as the change to the reviews, after lockMovie().
+func updateRating(ctx context.Context, tx *sql.Tx, movieID int64) error {
+	query := `
+		UPDATE movies
+		SET rating = COALESCE(r.average, 0), rating_count = r.count
+		FROM (
+			SELECT avg(score)::double precision AS average, count(*) AS count
+			FROM reviews
+			WHERE movie_id = $1
+		) AS r
+		WHERE id = $1`
+
+	_, err := tx.ExecContext(ctx, query, movieID)
+	return err
+}
+
+// Insert adds a review to a live movie and updates the movie's rating in the same
+// transaction.
+func (m ReviewModel) Insert(ctx context.Context, review *Review) error {
+	query := `
+		INSERT INTO reviews (movie_id, score, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Insert)
+	defer cancel()
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		if err := lockMovie(ctx, tx, review.MovieID); err != nil {
+			return err
+		}
+
+		err := tx.QueryRowContext(ctx, query, review.MovieID, review.Score, review.Body).Scan(
+			&review.ID,
+			&review.CreatedAt,
+			&review.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		return updateRating(ctx, tx, review.MovieID)
+	})
+
+	return contextError(ctx, err)
+}
+
+func (m ReviewModel) Get(ctx context.Context, movieID, id int64) (*Review, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, movie_id, score, body, version
+		FROM reviews
+		WHERE id = $1 AND movie_id = $2
+	`
+
+	var review Review
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Get)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, movieID).Scan(
+		&review.ID,
+		&review.CreatedAt,
+		&review.MovieID,
+		&review.Score,
+		&review.Body,
+		&review.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextError(ctx, err)
+		}
+	}
+
+	return &review, nil
+}
+
+// GetAll returns a page of the reviews of a movie.
+func (m ReviewModel) GetAll(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, movie_id, score, body, version
+		FROM reviews
+		WHERE movie_id = $1
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.List)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := []*Review{}
+	for rows.Next() {
+		var review Review
+
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.CreatedAt,
+			&review.MovieID,
+			&review.Score,
+			&review.Body,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, contextError(ctx, err)
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+
+	return reviews, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Update saves a review, using its version for optimistic locking, and updates the
+// movie's rating in the same transaction. It returns ErrRecordNotFound if the movie
+// isn't live.
+func (m ReviewModel) Update(ctx context.Context, review *Review) error {
+	query := `
+		UPDATE reviews
+		SET score = $1, body = $2, version = version + 1
+		WHERE id = $3 AND movie_id = $4 AND version = $5
+		RETURNING version
+	`
+
+	args := []interface{}{review.Score, review.Body, review.ID, review.MovieID, review.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Update)
+	defer cancel()
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		if err := lockMovie(ctx, tx, review.MovieID); err != nil {
+			return err
+		}
+
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&review.Version); err != nil {
+			return err
+		}
+
+		return updateRating(ctx, tx, review.MovieID)
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrRecordNotFound
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return contextError(ctx, err)
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a review and updates the movie's rating in the same transaction.
+func (m ReviewModel) Delete(ctx context.Context, movieID, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM reviews
+		WHERE id = $1 AND movie_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Delete)
+	defer cancel()
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		if err := lockMovie(ctx, tx, movieID); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, query, id, movieID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+
+		return updateRating(ctx, tx, movieID)
+	})
+
+	return contextError(ctx, err)
+}
@@ -0,0 +1,167 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MemoryReviewModel is the in-memory implementation of ReviewRepository. The reviews
+// are stored in the MemoryMovieModel it is attached to, which keeps the ratings of the
+// movies up to date under the same lock.
+type MemoryReviewModel struct {
+	movies *MemoryMovieModel
+}
+
+func (m MemoryReviewModel) Insert(ctx context.Context, review *Review) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	movie, ok := m.movies.movies[review.MovieID]
+	if !ok || movie.DeletedAt != nil {
+		return ErrRecordNotFound
+	}
+
+	review.ID = m.movies.nextReviewID
+	review.CreatedAt = time.Now().Truncate(time.Second)
+	review.Version = 1
+
+	m.movies.nextReviewID++
+	c := *review
+	m.movies.reviews[review.MovieID] = append(m.movies.reviews[review.MovieID], &c)
+	m.movies.updateRating(review.MovieID)
+
+	return nil
+}
+
+func (m MemoryReviewModel) Get(ctx context.Context, movieID, id int64) (*Review, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	for _, review := range m.movies.reviews[movieID] {
+		if review.ID == id {
+			c := *review
+			return &c, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryReviewModel) GetAll(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	matches := make([]*Review, 0, len(m.movies.reviews[movieID]))
+	for _, review := range m.movies.reviews[movieID] {
+		c := *review
+		matches = append(matches, &c)
+	}
+
+	column := filters.sortColumn()
+	desc := filters.sortDirection() == "DESC"
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+
+		cmp := compareInts(a.ID, b.ID)
+		if column == "score" {
+			cmp = compareInts(int64(a.Score), int64(b.Score))
+		}
+
+		if cmp != 0 {
+			return (cmp < 0) != desc
+		}
+		return a.ID < b.ID
+	})
+
+	start := min(filters.offset(), len(matches))
+	end := min(start+filters.limit(), len(matches))
+
+	return matches[start:end:end], calculateMetadata(len(matches), filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryReviewModel) Update(ctx context.Context, review *Review) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	movie, ok := m.movies.movies[review.MovieID]
+	if !ok || movie.DeletedAt != nil {
+		return ErrRecordNotFound
+	}
+
+	for _, stored := range m.movies.reviews[review.MovieID] {
+		if stored.ID == review.ID && stored.Version == review.Version {
+			review.Version++
+			stored.Score = review.Score
+			stored.Body = review.Body
+			stored.Version = review.Version
+			m.movies.updateRating(review.MovieID)
+			return nil
+		}
+	}
+
+	return ErrEditConflict
+}
+
+func (m MemoryReviewModel) Delete(ctx context.Context, movieID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.movies.mu.Lock()
+	defer m.movies.mu.Unlock()
+
+	movie, ok := m.movies.movies[movieID]
+	if !ok || movie.DeletedAt != nil {
+		return ErrRecordNotFound
+	}
+
+	reviews := m.movies.reviews[movieID]
+	for i, review := range reviews {
+		if review.ID == id {
+			m.movies.reviews[movieID] = append(reviews[:i:i], reviews[i+1:]...)
+			m.movies.updateRating(movieID)
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+// updateRating recomputes the aggregated rating of a movie from its reviews, like the
+// SQL updateRating(). The caller must hold the write lock.
+func (m *MemoryMovieModel) updateRating(movieID int64) {
+	movie := m.movies[movieID]
+	reviews := m.reviews[movieID]
+
+	movie.Rating = 0
+	movie.RatingCount = int32(len(reviews))
+
+	if len(reviews) == 0 {
+		return
+	}
+
+	total := 0
+	for _, review := range reviews {
+		total += int(review.Score)
+	}
+
+	movie.Rating = float64(total) / float64(len(reviews))
+}
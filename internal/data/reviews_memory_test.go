@@ -0,0 +1,37 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryReviewUpdate(t *testing.T) {
+	ctx := context.Background()
+	models := NewMemoryModels()
+	movie := insertTestMovie(t, models, "Alien", "sci-fi")
+
+	review := &Review{MovieID: movie.ID, Score: 8, Body: "Tense."}
+	if err := models.Reviews.Insert(ctx, review); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	stale := *review
+
+	review.Score = 9
+	if err := models.Reviews.Update(ctx, review); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if err := models.Reviews.Update(ctx, &stale); !errors.Is(err, ErrEditConflict) {
+		t.Errorf("Update() with a stale version error = %v, want ErrEditConflict", err)
+	}
+
+	if err := models.Movies.Delete(ctx, movie.ID, 0); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := models.Reviews.Update(ctx, review); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Update() of a trashed movie's review error = %v, want ErrRecordNotFound", err)
+	}
+}
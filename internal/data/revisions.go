@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Operations recorded in the movie revision history.
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionPoster  = "poster"
+)
+
+// Revision is a full snapshot of a movie as it was immediately after one of the
+// operations above was applied to it.
+type Revision struct {
+	MovieID   int64     `json:"movie_id"`             // ID of the movie this revision belongs to
+	Version   int32     `json:"version"`              // Version of the movie the snapshot was taken at
+	Operation string    `json:"operation"`            // Operation which produced this version
+	Movie     *Movie    `json:"movie"`                // Snapshot of the movie record
+	RequestID string    `json:"request_id,omitempty"` // ID of the API request which made the change
+	CreatedAt time.Time `json:"created_at"`           // Timestamp for when the change was made
+}
+
+type RevisionModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// insertRevision records a snapshot of the movie in the movie_revisions table. It must
+// be called within the same transaction as the change it describes, so that the
+// history can never disagree with the movies table.
+func insertRevision(ctx context.Context, tx *sql.Tx, operation string, movie *Movie) error {
+	return insertRevisionBatch(ctx, tx, operation, []*Movie{movie})
+}
+
+// insertRevisionBatch records a revision for each of the movies with one multi-row
+// INSERT statement.
+func insertRevisionBatch(ctx context.Context, tx *sql.Tx, operation string, movies []*Movie) error {
+	requestID := RequestIDFromContext(ctx)
+
+	values := make([]string, 0, len(movies))
+	args := make([]interface{}, 0, len(movies)*5)
+
+	for i, movie := range movies {
+		snapshot, err := json.Marshal(movie)
+		if err != nil {
+			return err
+		}
+
+		n := i * 5
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, movie.ID, movie.Version, operation, string(snapshot), requestID)
+	}
+
+	query := `
+		INSERT INTO movie_revisions (movie_id, version, operation, snapshot, request_id)
+		VALUES ` + strings.Join(values, ", ")
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetAll returns a page of the revisions of a movie.
+func (m RevisionModel) GetAll(ctx context.Context, movieID int64, filters Filters) ([]*Revision, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), movie_id, version, operation, snapshot, request_id, created_at
+		FROM movie_revisions
+		WHERE movie_id = $1
+		ORDER BY %s %s
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.List)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*Revision{}
+	for rows.Next() {
+		var revision Revision
+		var snapshot []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&revision.MovieID,
+			&revision.Version,
+			&revision.Operation,
+			&snapshot,
+			&revision.RequestID,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, contextError(ctx, err)
+		}
+
+		if err := json.Unmarshal(snapshot, &revision.Movie); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+
+	return revisions, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Get returns the revision of a movie at a specific version.
+func (m RevisionModel) Get(ctx context.Context, movieID int64, version int32) (*Revision, error) {
+	if movieID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT movie_id, version, operation, snapshot, request_id, created_at
+		FROM movie_revisions
+		WHERE movie_id = $1 AND version = $2
+	`
+
+	var revision Revision
+	var snapshot []byte
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeouts.Get)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, version).Scan(
+		&revision.MovieID,
+		&revision.Version,
+		&revision.Operation,
+		&snapshot,
+		&revision.RequestID,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextError(ctx, err)
+		}
+	}
+
+	if err := json.Unmarshal(snapshot, &revision.Movie); err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
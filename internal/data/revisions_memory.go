@@ -0,0 +1,63 @@
+package data
+
+import (
+	"context"
+	"sort"
+)
+
+// MemoryRevisionModel is the in-memory implementation of RevisionRepository. It reads
+// the revisions recorded by the MemoryMovieModel it is attached to.
+type MemoryRevisionModel struct {
+	movies *MemoryMovieModel
+}
+
+func (m MemoryRevisionModel) GetAll(ctx context.Context, movieID int64, filters Filters) ([]*Revision, Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	matches := append([]*Revision(nil), m.movies.revisions[movieID]...)
+
+	// Revisions are appended in version order, so only a descending sort needs work.
+	if filters.sortDirection() == "DESC" {
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].Version > matches[j].Version
+		})
+	}
+
+	start := min(filters.offset(), len(matches))
+	end := min(start+filters.limit(), len(matches))
+
+	revisions := make([]*Revision, 0, end-start)
+	for _, revision := range matches[start:end] {
+		revisions = append(revisions, copyRevision(revision))
+	}
+
+	return revisions, calculateMetadata(len(matches), filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryRevisionModel) Get(ctx context.Context, movieID int64, version int32) (*Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.movies.mu.RLock()
+	defer m.movies.mu.RUnlock()
+
+	for _, revision := range m.movies.revisions[movieID] {
+		if revision.Version == version {
+			return copyRevision(revision), nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func copyRevision(revision *Revision) *Revision {
+	c := *revision
+	c.Movie = copyMovie(revision.Movie)
+	return &c
+}
@@ -0,0 +1,289 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.abhishek/internal/validator"
+)
+
+// SearchLanguages lists the PostgreSQL text search configurations which can be used to
+// parse title searches. "simple" only lower-cases words, while the others also apply
+// the stemming rules and stop words of their language.
+var SearchLanguages = []string{"simple", "dutch", "english", "french", "german", "italian", "portuguese", "russian", "spanish"}
+
+// SearchModes lists the ways in which the title of a MovieSearch can be matched.
+// "exact" matches whole words (or prefixes) with full-text search, while "fuzzy"
+// tolerates typos by comparing the trigrams of the title and the search.
+var SearchModes = []string{"exact", "fuzzy"}
+
+// FuzzyThreshold is the trigram similarity, between 0 and 1, which a title must reach
+// to match a fuzzy search. It is the same as the pg_trgm default for the % operator.
+const FuzzyThreshold = 0.3
+
+// MovieSearch holds the criteria used to select movies in GetAll() and Export(). The
+// range bounds are inclusive, and a zero bound means that end of the range is open.
+type MovieSearch struct {
+	Title      string   // Words which must all appear in the title; "star*" matches as a prefix.
+	Language   string   // Text search configuration used for the title, one of SearchLanguages.
+	Mode       string   // How the title is matched, one of SearchModes. Defaults to "exact".
+	Genres     []string // Genres to filter on.
+	GenresMode string   // "all" if the movie must have every genre (the default), "any" for at least one.
+	YearMin    int      // Earliest release year.
+	YearMax    int      // Latest release year.
+	RuntimeMin int      // Shortest runtime, in minutes.
+	RuntimeMax int      // Longest runtime, in minutes.
+	Director   string   // Name of a person credited as the director.
+	Writer     string   // Name of a person credited as a writer.
+	Actor      string   // Name of a person credited as an actor.
+}
+
+// Match describes how well a movie matched a title search.
+type Match struct {
+	Relevance float64 `json:"relevance"`          // Relevance score, higher is better
+	Headline  string  `json:"headline,omitempty"` // Title with the matching words wrapped in <b></b> tags, exact searches only
+}
+
+func ValidateMovieSearch(v *validator.Validator, s MovieSearch) {
+	v.Check(len(s.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Check(validator.In(s.Language, SearchLanguages...), "language", "invalid language value")
+	v.Check(validator.In(s.Mode, SearchModes...), "mode", "must be exact or fuzzy")
+
+	// A fuzzy search compares titles with the words of the search, so it needs some.
+	if s.fuzzy() {
+		v.Check(s.fuzzyText() != "", "title", "must be provided for a fuzzy search")
+	}
+	v.Check(validator.In(s.GenresMode, "all", "any"), "genres_mode", "must be all or any")
+
+	// The bounds follow the same rules as the year and runtime of a movie in
+	// ValidateMovie(), since a range outside of them could never match anything.
+	for key, year := range map[string]int{"year_min": s.YearMin, "year_max": s.YearMax} {
+		if year != 0 {
+			v.Check(year >= 1888, key, "must be greater than 1888")
+			v.Check(year <= time.Now().Year(), key, "must not be in the future")
+		}
+	}
+
+	for key, runtime := range map[string]int{"runtime_min": s.RuntimeMin, "runtime_max": s.RuntimeMax} {
+		if runtime != 0 {
+			v.Check(runtime > 0, key, "must be a positive integer")
+		}
+	}
+
+	if s.YearMin != 0 && s.YearMax != 0 {
+		v.Check(s.YearMin <= s.YearMax, "year_max", "must not be less than year_min")
+	}
+
+	if s.RuntimeMin != 0 && s.RuntimeMax != 0 {
+		v.Check(s.RuntimeMin <= s.RuntimeMax, "runtime_max", "must not be less than runtime_min")
+	}
+
+	for role, name := range s.people() {
+		v.Check(len(name) <= 500, role, "must not be more than 500 bytes long")
+	}
+}
+
+// people returns the person name to filter on for each credit role. Roles which
+// aren't filtered on have an empty name.
+func (s MovieSearch) people() map[string]string {
+	return map[string]string{"director": s.Director, "writer": s.Writer, "actor": s.Actor}
+}
+
+// ResolveSearchGenres replaces each of the genres to filter on with the slug of the
+// catalogue genre it names, since movies only store slugs. Genres which aren't in the
+// catalogue are reported as errors rather than left to silently match nothing.
+func ResolveSearchGenres(v *validator.Validator, s *MovieSearch, genres *GenreCatalogue) {
+	for i, genre := range s.Genres {
+		slug, ok := genres.Resolve(genre)
+		if !ok {
+			v.AddError("genres", fmt.Sprintf("%q is not a known genre", genre))
+			continue
+		}
+		s.Genres[i] = slug
+	}
+}
+
+func (s MovieSearch) anyGenre() bool {
+	return s.GenresMode == "any"
+}
+
+func (s MovieSearch) fuzzy() bool {
+	return s.Mode == "fuzzy"
+}
+
+// fuzzyText returns the words of the title search separated by single spaces, which
+// is what the trigrams of a fuzzy search are taken from.
+func (s MovieSearch) fuzzyText() string {
+	return strings.Join(tokenize(s.Title), " ")
+}
+
+// suggestionFilters selects the single most similar movie to a fuzzy search.
+var suggestionFilters = Filters{Page: 1, PageSize: 1, Sort: "relevance", SortSafelist: []string{"relevance"}}
+
+// suggestion returns the fuzzy search used to find a "did you mean" suggestion, and
+// whether one is worth looking for. That is only the case when an exact title search
+// found nothing at all; later pages and fuzzy searches never get a suggestion.
+func (s MovieSearch) suggestion(filters Filters, movies []*Movie) (MovieSearch, bool) {
+	if s.fuzzyText() == "" || s.fuzzy() || len(movies) > 0 || filters.Cursor != "" || filters.Page > 1 {
+		return MovieSearch{}, false
+	}
+
+	s.Mode = "fuzzy"
+	return s, true
+}
+
+// language returns the text search configuration to use, checking that it is in the
+// safelist since it is interpolated into the SQL.
+func (s MovieSearch) language() string {
+	if s.Language == "" {
+		return "simple"
+	}
+
+	if validator.In(s.Language, SearchLanguages...) {
+		return s.Language
+	}
+
+	panic("unsafe search language: " + s.Language)
+}
+
+// matchesNothing reports whether a title search was given which contains no words at
+// all (only punctuation, say), in which case no movie can match it.
+func (s MovieSearch) matchesNothing() bool {
+	return s.Title != "" && s.tsQuery() == ""
+}
+
+// tsQuery converts the title search into a to_tsquery() expression in which every
+// word must match. A word ending in "*" becomes a prefix match, so "star*" is turned
+// into "star:*". Words are reduced to letters and digits first, which guarantees that
+// the result is valid tsquery syntax whatever the client sent.
+func (s MovieSearch) tsQuery() string {
+	terms := []string{}
+
+	for _, term := range s.terms() {
+		if term.prefix {
+			terms = append(terms, term.word+":*")
+		} else {
+			terms = append(terms, term.word)
+		}
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+type searchTerm struct {
+	word   string
+	prefix bool
+}
+
+// terms splits the title search into lower-cased words, noting which of them should
+// match as prefixes.
+func (s MovieSearch) terms() []searchTerm {
+	terms := []searchTerm{}
+
+	for _, field := range strings.Fields(s.Title) {
+		words := tokenize(field)
+
+		for i, word := range words {
+			terms = append(terms, searchTerm{
+				word:   word,
+				prefix: i == len(words)-1 && strings.HasSuffix(field, "*"),
+			})
+		}
+	}
+
+	return terms
+}
+
+// movieQuery describes the movies selected by MovieModel.list(), along with the
+// expressions used to score and highlight them.
+type movieQuery struct {
+	where     string        // WHERE clause, with placeholders numbered from $1
+	args      []interface{} // Arguments for the placeholders in all of the clauses
+	relevance string        // Expression computing the relevance of each row
+	headline  string        // Expression computing the highlighted title of each row
+}
+
+// query returns the movieQuery which selects the live movies matching the search.
+func (s MovieSearch) query() movieQuery {
+	if s.fuzzy() {
+		return s.fuzzyQuery()
+	}
+
+	// The language is safelisted, so it is safe to interpolate. Doing so (rather than
+	// passing it as a placeholder) lets the planner match the expression indexes on
+	// to_tsvector(<language>, title).
+	document := fmt.Sprintf("to_tsvector('%s', title)", s.language())
+	tsquery := fmt.Sprintf("to_tsquery('%s', $1)", s.language())
+
+	q := movieQuery{
+		where: fmt.Sprintf(`
+			deleted_at IS NULL
+			AND (%s @@ %s OR $1 = '')
+			AND %s`, document, tsquery, s.filterClause()),
+		args:      append([]interface{}{s.tsQuery()}, s.filterArgs()...),
+		relevance: "0::real",
+		headline:  "NULL",
+	}
+
+	if s.Title != "" {
+		q.relevance = fmt.Sprintf("ts_rank_cd(%s, %s)", document, tsquery)
+		q.headline = fmt.Sprintf("ts_headline('%s', title, %s, 'HighlightAll=true')", s.language(), tsquery)
+	}
+
+	return q
+}
+
+// fuzzyQuery returns the movieQuery for a fuzzy search, which selects the movies whose
+// title is similar enough to the search text and scores them by that similarity.
+func (s MovieSearch) fuzzyQuery() movieQuery {
+	// The % operator is what lets the planner use the trigram index, but it compares
+	// against the pg_trgm.similarity_threshold setting. The explicit similarity() check
+	// makes sure FuzzyThreshold applies whatever that setting is.
+	return movieQuery{
+		where: fmt.Sprintf(`
+			deleted_at IS NULL
+			AND title %% $1 AND similarity(title, $1) >= %g
+			AND %s`, FuzzyThreshold, s.filterClause()),
+		args:      append([]interface{}{s.fuzzyText()}, s.filterArgs()...),
+		relevance: "similarity(title, $1)",
+		headline:  "''",
+	}
+}
+
+// filterClause returns the part of the WHERE clause which applies the genre, year,
+// runtime and people filters, using placeholders $2 to $9. Each condition is skipped
+// when its filter is empty or zero. People are matched by name, ignoring case.
+func (s MovieSearch) filterClause() string {
+	// @> requires every genre to be present, while && requires them to overlap.
+	operator := "@>"
+	if s.anyGenre() {
+		operator = "&&"
+	}
+
+	return fmt.Sprintf(`(genres %s $2 OR $2 = '{}')
+			AND (year >= $3 OR $3 = 0)
+			AND (year <= $4 OR $4 = 0)
+			AND (runtime >= $5 OR $5 = 0)
+			AND (runtime <= $6 OR $6 = 0)
+			AND %s
+			AND %s
+			AND %s`, operator, creditClause("director", 7), creditClause("writer", 8), creditClause("actor", 9))
+}
+
+// creditClause returns a condition which checks that the movie credits the person named
+// by placeholder n with the given role.
+func creditClause(role string, n int) string {
+	return fmt.Sprintf(`($%[2]d = '' OR EXISTS (
+				SELECT 1
+				FROM movie_credits c
+				INNER JOIN people p ON p.id = c.person_id
+				WHERE c.movie_id = movies.id AND c.role = '%[1]s' AND lower(p.name) = lower($%[2]d)
+			))`, role, n)
+}
+
+// filterArgs returns the arguments for the placeholders in filterClause().
+func (s MovieSearch) filterArgs() []interface{} {
+	return []interface{}{pq.Array(s.Genres), s.YearMin, s.YearMax, s.RuntimeMin, s.RuntimeMax, s.Director, s.Writer, s.Actor}
+}
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"greenlight.abhishek/internal/validator"
+)
+
+func TestValidateMovieSearch(t *testing.T) {
+	valid := MovieSearch{Language: "simple", Mode: "exact", GenresMode: "all"}
+
+	tests := []struct {
+		name   string
+		modify func(s *MovieSearch)
+		key    string // Key of the expected error, empty if the search is valid
+	}{
+		{"defaults", func(s *MovieSearch) {}, ""},
+		{"title", func(s *MovieSearch) { s.Title = "star*" }, ""},
+		{"long title", func(s *MovieSearch) { s.Title = strings.Repeat("a", 501) }, "title"},
+		{"other language", func(s *MovieSearch) { s.Language = "english" }, ""},
+		{"unknown language", func(s *MovieSearch) { s.Language = "klingon" }, "language"},
+		{"unknown mode", func(s *MovieSearch) { s.Mode = "regex" }, "mode"},
+		{"fuzzy with title", func(s *MovieSearch) { s.Mode = "fuzzy"; s.Title = "alein" }, ""},
+		{"fuzzy without title", func(s *MovieSearch) { s.Mode = "fuzzy" }, "title"},
+		{"fuzzy with punctuation only", func(s *MovieSearch) { s.Mode = "fuzzy"; s.Title = "!?" }, "title"},
+		{"unknown genres mode", func(s *MovieSearch) { s.GenresMode = "none" }, "genres_mode"},
+		{"year range", func(s *MovieSearch) { s.YearMin = 1990; s.YearMax = 2000 }, ""},
+		{"year too early", func(s *MovieSearch) { s.YearMin = 1800 }, "year_min"},
+		{"year range reversed", func(s *MovieSearch) { s.YearMin = 2000; s.YearMax = 1990 }, "year_max"},
+		{"negative runtime", func(s *MovieSearch) { s.RuntimeMax = -1 }, "runtime_max"},
+		{"runtime range reversed", func(s *MovieSearch) { s.RuntimeMin = 120; s.RuntimeMax = 90 }, "runtime_max"},
+		{"long person name", func(s *MovieSearch) { s.Actor = strings.Repeat("a", 501) }, "actor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := valid
+			tt.modify(&search)
+
+			v := validator.New()
+			ValidateMovieSearch(v, search)
+
+			if tt.key == "" {
+				if !v.Valid() {
+					t.Errorf("unexpected errors %v", v.Errors)
+				}
+				return
+			}
+
+			if _, ok := v.Errors[tt.key]; !ok {
+				t.Errorf("errors = %v, want an error for %q", v.Errors, tt.key)
+			}
+		})
+	}
+}
+
+func TestTSQuery(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"star wars", "star & wars"},
+		{"Star*", "star:*"},
+		{"  it's   alive!  ", "it & s & alive"},
+		{"!?", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := (MovieSearch{Title: tt.title}).tsQuery(); got != tt.want {
+			t.Errorf("tsQuery(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestResolveSearchGenres(t *testing.T) {
+	catalogue := NewGenreCatalogue(DefaultGenres)
+
+	search := MovieSearch{Genres: []string{"Sci-Fi", "Drama", "SF"}}
+
+	v := validator.New()
+	ResolveSearchGenres(v, &search, catalogue)
+
+	if !v.Valid() {
+		t.Fatalf("unexpected errors %v", v.Errors)
+	}
+
+	want := []string{"sci-fi", "drama", "sci-fi"}
+	for i := range want {
+		if search.Genres[i] != want[i] {
+			t.Errorf("Genres = %v, want %v", search.Genres, want)
+			break
+		}
+	}
+
+	search = MovieSearch{Genres: []string{"drama", "nope"}}
+
+	v = validator.New()
+	ResolveSearchGenres(v, &search, catalogue)
+
+	if _, ok := v.Errors["genres"]; !ok {
+		t.Errorf("errors = %v, want an error for genres", v.Errors)
+	}
+}
@@ -0,0 +1,354 @@
+// Package migrate applies the SQL migrations in the migrations directory to a
+// PostgreSQL database.
+//
+// The current schema version is kept in a schema_migrations table with the same layout
+// as the one used by the golang-migrate tool, so a database which was migrated with that
+// tool can be taken over without any changes.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	ErrDirty        = errors.New("database schema is dirty")
+	ErrNoMigration  = errors.New("no migration with that version")
+	ErrInvalidSteps = errors.New("number of steps must be positive")
+)
+
+// lockID identifies the advisory lock held while migrations run, so that two migrators
+// (for example, two API instances started with -migrate-on-start) never run at once.
+const lockID = 4_181_253_920_125_839_427
+
+// filenameRX matches migration filenames such as 000001_create_movies_table.up.sql.
+var filenameRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a single schema change, with the SQL to apply it and to reverse it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes the state of a database's schema.
+type Status struct {
+	Version int64        // Version of the last applied migration, zero if none have been
+	Dirty   bool         // Whether a migration failed part way through
+	Latest  int64        // Version of the newest migration known to the migrator
+	Pending []*Migration // Migrations which haven't been applied yet, oldest first
+}
+
+// Behind reports whether the schema is missing any of the known migrations, or is in
+// an unknown state because a migration failed.
+func (s Status) Behind() bool {
+	return s.Dirty || s.Version < s.Latest
+}
+
+// Migrator applies a set of migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration // Sorted by version
+}
+
+// New returns a Migrator for the migration files found in the root of fsys.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		matches := filenameRX.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has files with different names", version)
+		}
+
+		if matches[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	m := &Migrator{db: db}
+
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+		m.migrations = append(m.migrations, migration)
+	}
+
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+
+	return m, nil
+}
+
+// Latest returns the version of the newest migration, which is the schema version the
+// application expects.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns the state of the database's schema.
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	var status Status
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		status, err = m.status(ctx, conn)
+		return err
+	})
+
+	return status, err
+}
+
+// Up applies all of the pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		return m.migrateTo(ctx, conn, m.Latest())
+	})
+}
+
+// Down reverses the given number of the most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return ErrInvalidSteps
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		status, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		applied := m.applied(status.Version)
+
+		target := int64(0)
+		if steps < len(applied) {
+			target = applied[len(applied)-steps-1].Version
+		}
+
+		return m.migrateTo(ctx, conn, target)
+	})
+}
+
+// Goto applies or reverses migrations until the schema is at the given version. Zero
+// reverses every migration.
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return ErrNoMigration
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		return m.migrateTo(ctx, conn, version)
+	})
+}
+
+// Force records the schema as being at the given version and clears the dirty flag,
+// without running any migrations. It is for recovering from a failed migration once
+// the database has been repaired by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return ErrNoMigration
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := setVersion(ctx, tx, version); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// withLock runs fn on a single connection which holds the migration advisory lock,
+// creating the schema_migrations table first if necessary. The lock is held at session
+// level, so it has to be taken and released on the connection fn uses.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, int64(lockID)); err != nil {
+		return err
+	}
+
+	// Unlock with a fresh context, so that the lock is released even when ctx has been
+	// cancelled.
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, int64(lockID))
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// status reads the schema version. It must be called while holding the lock.
+func (m *Migrator) status(ctx context.Context, conn *sql.Conn) (Status, error) {
+	status := Status{Latest: m.Latest()}
+
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&status.Version, &status.Dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Status{}, err
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version > status.Version {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+
+	return status, nil
+}
+
+// migrateTo applies or reverses migrations, one at a time, until the schema is at the
+// target version. It must be called while holding the lock.
+func (m *Migrator) migrateTo(ctx context.Context, conn *sql.Conn, target int64) error {
+	status, err := m.status(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	if status.Dirty {
+		return fmt.Errorf("%w at version %d, fix it and then use force", ErrDirty, status.Version)
+	}
+
+	// Apply the pending migrations up to and including the target...
+	for _, migration := range status.Pending {
+		if migration.Version > target {
+			break
+		}
+
+		if err := run(ctx, conn, migration.Version, migration.Up); err != nil {
+			return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	// ...or reverse the applied migrations after it, newest first. Each one leaves the
+	// schema at the version of the migration before it.
+	applied := m.applied(status.Version)
+
+	for i := len(applied) - 1; i >= 0 && applied[i].Version > target; i-- {
+		previous := int64(0)
+		if i > 0 {
+			previous = applied[i-1].Version
+		}
+
+		migration := applied[i]
+		if migration.Down == "" {
+			return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+
+		if err := run(ctx, conn, previous, migration.Down); err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// run executes the SQL of a migration and records the resulting schema version in the
+// same transaction, so that a failed migration leaves no trace. The dirty flag is only
+// set by migration tools which don't use transactions.
+func run(ctx context.Context, conn *sql.Conn, version int64, query string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	if err := setVersion(ctx, tx, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setVersion records the schema version. Version zero means that no migrations have
+// been applied, which is recorded as an empty table.
+func setVersion(ctx context.Context, tx *sql.Tx, version int64) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+
+	if version == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version)
+	return err
+}
+
+// applied returns the known migrations up to and including the given version.
+func (m *Migrator) applied(version int64) []*Migration {
+	var applied []*Migration
+	for _, migration := range m.migrations {
+		if migration.Version <= version {
+			applied = append(applied, migration)
+		}
+	}
+	return applied
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"greenlight.abhishek/migrations"
+)
+
+func file(contents string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(contents)}
+}
+
+func TestNew(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_add_index.up.sql":     file("CREATE INDEX"),
+		"000002_add_index.down.sql":   file("DROP INDEX"),
+		"000001_create_table.up.sql":  file("CREATE TABLE"),
+		"000010_add_column.up.sql":    file("ALTER TABLE"),
+		"README.md":                   file("not a migration"),
+		"000003_no_direction.sql":     file("ignored"),
+		"migrations.go":               file("package migrations"),
+		"000001_create_table.down.sq": file("ignored"),
+	}
+
+	m, err := New(nil, fsys)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "create_table", Up: "CREATE TABLE"},
+		{Version: 2, Name: "add_index", Up: "CREATE INDEX", Down: "DROP INDEX"},
+		{Version: 10, Name: "add_column", Up: "ALTER TABLE"},
+	}
+
+	if len(m.migrations) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(m.migrations), len(want))
+	}
+
+	for i, migration := range m.migrations {
+		if *migration != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, *migration, want[i])
+		}
+	}
+
+	if latest := m.Latest(); latest != 10 {
+		t.Errorf("Latest() = %d, want 10", latest)
+	}
+
+	if m.find(2) == nil || m.find(3) != nil {
+		t.Errorf("find() didn't match the known versions")
+	}
+
+	if applied := m.applied(2); len(applied) != 2 {
+		t.Errorf("applied(2) returned %d migrations, want 2", len(applied))
+	}
+}
+
+func TestNewRejectsInvalidMigrations(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"version zero", fstest.MapFS{
+			"000000_init.up.sql": file("SELECT 1"),
+		}},
+		{"no up file", fstest.MapFS{
+			"000001_init.down.sql": file("SELECT 1"),
+		}},
+		{"mismatched names", fstest.MapFS{
+			"000001_init.up.sql":    file("SELECT 1"),
+			"000001_other.down.sql": file("SELECT 1"),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(nil, tt.fsys); err == nil {
+				t.Errorf("New() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestStatusBehind(t *testing.T) {
+	tests := []struct {
+		status Status
+		want   bool
+	}{
+		{Status{Version: 10, Latest: 10}, false},
+		{Status{Version: 11, Latest: 10}, false},
+		{Status{Version: 9, Latest: 10}, true},
+		{Status{Version: 10, Latest: 10, Dirty: true}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.Behind(); got != tt.want {
+			t.Errorf("%+v.Behind() = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+// TestEmbeddedMigrations checks that the migrations shipped with the application are
+// numbered without gaps and can all be reversed.
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := New(nil, migrations.Files)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i, migration := range m.migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %d_%s is out of sequence", migration.Version, migration.Name)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS movie_revisions (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    version integer NOT NULL,
+    operation text NOT NULL,
+    snapshot jsonb NOT NULL,
+    request_id text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (movie_id, version)
+);
+
+-- Seed the history with the current state of every existing movie, so that each movie
+-- has at least one revision. The snapshot uses the same layout as the Movie JSON.
+INSERT INTO movie_revisions (movie_id, version, operation, snapshot, created_at)
+SELECT id, version, 'create', jsonb_strip_nulls(jsonb_build_object(
+    'id', id,
+    'created_at', created_at,
+    'title', title,
+    'year', year,
+    'runtime', runtime || ' mins',
+    'genres', genres,
+    'version', version,
+    'deleted_at', deleted_at
+)), created_at
+FROM movies
+ON CONFLICT DO NOTHING;
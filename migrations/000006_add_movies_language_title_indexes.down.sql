@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS movies_title_english_idx;
+
+DROP INDEX IF EXISTS movies_title_french_idx;
+
+DROP INDEX IF EXISTS movies_title_german_idx;
+
+DROP INDEX IF EXISTS movies_title_spanish_idx;
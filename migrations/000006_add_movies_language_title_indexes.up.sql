@@ -0,0 +1,9 @@
+-- Title searches can use any of the languages in data.SearchLanguages. Only the most
+-- commonly used configurations get an index; the others fall back to a sequential scan.
+CREATE INDEX IF NOT EXISTS movies_title_english_idx ON movies USING GIN (to_tsvector ('english', title));
+
+CREATE INDEX IF NOT EXISTS movies_title_french_idx ON movies USING GIN (to_tsvector ('french', title));
+
+CREATE INDEX IF NOT EXISTS movies_title_german_idx ON movies USING GIN (to_tsvector ('german', title));
+
+CREATE INDEX IF NOT EXISTS movies_title_spanish_idx ON movies USING GIN (to_tsvector ('spanish', title));
@@ -0,0 +1,2 @@
+-- Movie genres stay normalized to slugs, which are still valid free-text genres.
+DROP TABLE IF EXISTS genres;
@@ -0,0 +1,67 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    slug text NOT NULL UNIQUE,
+    name text NOT NULL,
+    synonyms text[] NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+-- The default catalogue, which must be kept in step with data.DefaultGenres.
+INSERT INTO genres (slug, name, synonyms)
+VALUES
+    ('action', 'Action', '{}'),
+    ('adventure', 'Adventure', '{}'),
+    ('animation', 'Animation', '{animated,cartoon}'),
+    ('comedy', 'Comedy', '{}'),
+    ('crime', 'Crime', '{}'),
+    ('documentary', 'Documentary', '{}'),
+    ('drama', 'Drama', '{}'),
+    ('family', 'Family', '{}'),
+    ('fantasy', 'Fantasy', '{}'),
+    ('history', 'History', '{historical}'),
+    ('horror', 'Horror', '{}'),
+    ('music', 'Music', '{musical}'),
+    ('mystery', 'Mystery', '{}'),
+    ('romance', 'Romance', '{}'),
+    ('sci-fi', 'Science Fiction', '{scifi,sf}'),
+    ('thriller', 'Thriller', '{}'),
+    ('war', 'War', '{}'),
+    ('western', 'Western', '{}')
+ON CONFLICT (slug) DO NOTHING;
+
+-- genre_key() normalizes a genre name in the same way as genreKey() in internal/data.
+-- It is only needed while this migration runs, so it lives in the session's temporary
+-- schema.
+CREATE FUNCTION pg_temp.genre_key (name text) RETURNS text AS $$
+    SELECT trim(BOTH '-' FROM regexp_replace(lower(name), '[^[:alnum:]]+', '-', 'g'))
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Add a genre for every free-text genre already in use which doesn't match any name of
+-- a genre in the catalogue.
+INSERT INTO genres (slug, name)
+SELECT DISTINCT pg_temp.genre_key (genre), initcap(replace(pg_temp.genre_key (genre), '-', ' '))
+FROM movies, unnest(genres) AS genre
+WHERE pg_temp.genre_key (genre) <> ''
+AND NOT EXISTS (
+    SELECT 1
+    FROM genres g, unnest(ARRAY[g.slug, g.name] || g.synonyms) AS alias
+    WHERE pg_temp.genre_key (alias) = pg_temp.genre_key (genre)
+)
+ON CONFLICT (slug) DO NOTHING;
+
+-- Replace the genres of every movie with the slugs of the genres they name, dropping any
+-- duplicates this creates while keeping the original order.
+UPDATE movies
+SET genres = ARRAY(
+    SELECT resolved.slug
+    FROM unnest(movies.genres) WITH ORDINALITY AS m (genre, n)
+    CROSS JOIN LATERAL (
+        SELECT g.slug
+        FROM genres g, unnest(ARRAY[g.slug, g.name] || g.synonyms) AS alias
+        WHERE pg_temp.genre_key (alias) = pg_temp.genre_key (m.genre)
+        LIMIT 1
+    ) AS resolved
+    GROUP BY resolved.slug
+    ORDER BY min(m.n)
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS reviews;
+
+DROP INDEX IF EXISTS movies_rating_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS rating;
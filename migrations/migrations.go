@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL migration files, so that the binaries can apply
+// them without the files being deployed alongside.
+package migrations
+
+import "embed"
+
+// Files holds every migration, named NNNNNN_description.up.sql and
+// NNNNNN_description.down.sql.
+//
+//go:embed *.sql
+var Files embed.FS